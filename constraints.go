@@ -0,0 +1,237 @@
+package flage
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// ErrConflictingFlags is returned by Check when flags that can't be used
+// together are set.
+var ErrConflictingFlags = errors.New("conflicting flags")
+
+type constraintKind int
+
+const (
+	exactlyOne constraintKind = iota
+	atMostOne
+	requires
+)
+
+// Constraint is a relationship between flags registered by StructVar, which
+// Check verifies after parsing. See AddConstraints.
+type Constraint struct {
+	kind  constraintKind
+	names []string // flag names, without the leading "-"
+}
+
+// ExactlyOne requires exactly one of the given flags to be set
+func ExactlyOne(names ...string) Constraint {
+	return Constraint{kind: exactlyOne, names: names}
+}
+
+// AtMostOne allows at most one of the given flags to be set
+func AtMostOne(names ...string) Constraint {
+	return Constraint{kind: atMostOne, names: names}
+}
+
+// Requires requires the other flags to be set when name is set
+func Requires(name string, others ...string) Constraint {
+	return Constraint{kind: requires, names: append([]string{name}, others...)}
+}
+
+func (c Constraint) String() string {
+	switch c.kind {
+	case exactlyOne:
+		return "exactly one of " + flagList(c.names)
+	case atMostOne:
+		return "at most one of " + flagList(c.names)
+	default:
+		return fmt.Sprintf("-%s requires %s", c.names[0], flagList(c.names[1:]))
+	}
+}
+
+// flagList formats flag names as "-a, -b, -c"
+func flagList(names []string) string {
+	return "-" + strings.Join(names, ", -")
+}
+
+// constraint is a Constraint whose flags have been looked up
+type constraint struct {
+	Constraint
+	flags []*structFlag
+}
+
+// check returns an error if the constraint isn't satisfied
+func (c *constraint) check() error {
+	var set []string
+	for i, sf := range c.flags {
+		if sf.set {
+			set = append(set, c.names[i])
+		}
+	}
+	switch c.kind {
+	case exactlyOne:
+		if len(set) == 0 {
+			return fmt.Errorf("%w: one of %s", ErrMissingRequiredFlag, flagList(c.names))
+		}
+		fallthrough
+	case atMostOne:
+		if len(set) > 1 {
+			return fmt.Errorf("%w: only one of %s can be given, got %s", ErrConflictingFlags, flagList(c.names), flagList(set))
+		}
+	case requires:
+		if !c.flags[0].set {
+			return nil
+		}
+		var missing []string
+		for i, sf := range c.flags[1:] {
+			if !sf.set {
+				missing = append(missing, c.names[i+1])
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("%w: %s (required by -%s)", ErrMissingRequiredFlag, flagList(missing), c.names[0])
+		}
+	}
+	return nil
+}
+
+// inactive returns true if any of the flags of the constraint belong to a
+// variant that wasn't selected
+func (c *constraint) inactive() bool {
+	for _, sf := range c.flags {
+		if sf.owner.inactiveVariant() != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// usage describes the constraint in the help of the flag at index i
+func (c *constraint) usage(i int) string {
+	switch c.kind {
+	case exactlyOne:
+		return "(exactly one of " + flagList(c.names) + ")"
+	case atMostOne:
+		others := make([]string, 0, len(c.names)-1)
+		others = append(others, c.names[:i]...)
+		others = append(others, c.names[i+1:]...)
+		return "(conflicts with " + flagList(others) + ")"
+	default:
+		if i != 0 {
+			return ""
+		}
+		return "(requires " + flagList(c.names[1:]) + ")"
+	}
+}
+
+// AddConstraints adds constraints between flags in fs, which Check verifies
+// after parsing. The flags must have been registered by StructVar. The
+// constraints are also described in the help of each flag.
+//
+// Constraints can also be declared with options in flage tags, see StructVar.
+//
+// If fs is nil, then flag.CommandLine is used instead.
+func AddConstraints(fs *flag.FlagSet, constraints ...Constraint) error {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	for _, c := range constraints {
+		if len(c.names) < 2 {
+			return fmt.Errorf("constraint %s: expected at least 2 flags", c)
+		}
+		resolved := &constraint{Constraint: c, flags: make([]*structFlag, len(c.names))}
+		for i, name := range c.names {
+			sf := lookupStructFlag(fs, name)
+			if sf == nil {
+				return fmt.Errorf("constraint %s: -%s is not a flag registered by StructVar", c, name)
+			}
+			resolved.flags[i] = sf
+		}
+		for i, sf := range resolved.flags {
+			sf.constraints = append(sf.constraints, resolved)
+			if usage := resolved.usage(i); usage != "" {
+				for _, name := range sf.names {
+					f := fs.Lookup(name)
+					f.Usage = strings.TrimSpace(f.Usage + " " + usage)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// lookupStructFlag returns the struct flag with the given name in fs, or nil
+func lookupStructFlag(fs *flag.FlagSet, name string) *structFlag {
+	f := fs.Lookup(name)
+	if f == nil {
+		return nil
+	}
+	switch v := f.Value.(type) {
+	case *structFlag:
+		return v
+	case *deprecatedFlag:
+		return v.structFlag
+	case *negatedFlag:
+		return v.structFlag
+	}
+	return nil
+}
+
+// checkConstraints returns the errors of every constraint added to fs
+func checkConstraints(fs *flag.FlagSet) []error {
+	var errs []error
+	seen := make(map[*constraint]bool)
+	visitStructFlags(fs, func(sf *structFlag) {
+		for _, c := range sf.constraints {
+			if seen[c] {
+				continue
+			}
+			seen[c] = true
+			if c.inactive() {
+				continue
+			}
+			if err := c.check(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	})
+	return errs
+}
+
+// tagConstraints collects the constraints declared in flage tags while
+// registering a struct.
+type tagConstraints struct {
+	groups      map[string]*Constraint // by kind and group name
+	constraints []*Constraint
+}
+
+// group adds name to the constraint of the given kind and group
+func (t *tagConstraints) group(kind constraintKind, group, name string) {
+	key := fmt.Sprint(kind, ":", group)
+	c := t.groups[key]
+	if c == nil {
+		if t.groups == nil {
+			t.groups = make(map[string]*Constraint)
+		}
+		c = &Constraint{kind: kind}
+		t.groups[key] = c
+		t.constraints = append(t.constraints, c)
+	}
+	c.names = append(c.names, name)
+}
+
+func (t *tagConstraints) requires(name string, others []string) {
+	c := Requires(name, others...)
+	t.constraints = append(t.constraints, &c)
+}
+
+func (t *tagConstraints) list() []Constraint {
+	list := make([]Constraint, len(t.constraints))
+	for i, c := range t.constraints {
+		list[i] = *c
+	}
+	return list
+}
@@ -0,0 +1,123 @@
+package flage
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestConstraints(t *testing.T) {
+	type TLS struct {
+		Cert string `flage:"cert,,certificate file"`
+		Key  string `flage:"key;requires=cert,,key file"`
+	}
+	type Example struct {
+		File  string `flage:"file;exactlyone=input,,file to read"`
+		URL   string `flage:"url;exactlyone=input,,url to read"`
+		Stdin bool   `flage:"stdin;exactlyone=input,,read stdin"`
+		JSON  bool   `flage:"json;atmostone=format,,output json"`
+		YAML  bool   `flage:"yaml;atmostone=format,,output yaml"`
+		TLS   TLS    `flage:"tls"`
+	}
+
+	tests := []struct {
+		args []string
+		errs []string
+	}{
+		{args: []string{"-file", "a.txt"}},
+		{args: []string{"-stdin", "-json", "-tls.cert", "a.crt", "-tls.key", "a.key"}},
+		{args: []string{"-url", "x", "-tls.cert", "a.crt"}},
+		{args: nil, errs: []string{"missing required flag: one of -file, -url, -stdin"}},
+		{args: []string{"-file", "a", "-url", "b"}, errs: []string{"conflicting flags: only one of -file, -url, -stdin can be given, got -file, -url"}},
+		{args: []string{"-stdin", "-json", "-yaml"}, errs: []string{"conflicting flags: only one of -json, -yaml can be given, got -json, -yaml"}},
+		{args: []string{"-stdin", "-tls.key", "a.key"}, errs: []string{"missing required flag: -tls.cert (required by -tls.key)"}},
+	}
+	for _, tc := range tests {
+		var example Example
+		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+		if err := fs.Parse(tc.args); err != nil {
+			t.Fatalf("%v: failed to parse flags: %s", tc.args, err.Error())
+		}
+		err := Check(fs)
+		if len(tc.errs) == 0 && err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.args, err)
+		}
+		for _, s := range tc.errs {
+			if err == nil || !strings.Contains(err.Error(), s) {
+				t.Errorf("%v: expected error to contain %q, got %v", tc.args, s, err)
+			}
+		}
+	}
+
+	t.Run("counts values from env as set", func(t *testing.T) {
+		type Example struct {
+			File string `flage:"file;atmostone=input"`
+			URL  string `flage:"url;atmostone=input" flage-env:"APP_URL"`
+		}
+		var example Example
+		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+		if err := fs.Parse([]string{"-file", "a"}); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		if err := ApplyEnv(fs, NewEnv(nil, EnvMap{"APP_URL": {"b"}})); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := Check(fs); !errors.Is(err, ErrConflictingFlags) {
+			t.Errorf("expected ErrConflictingFlags, got %v", err)
+		}
+	})
+
+	t.Run("shows constraints in help", func(t *testing.T) {
+		var example Example
+		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+		var buf bytes.Buffer
+		fs.SetOutput(&buf)
+		fs.Usage()
+		for _, s := range []string{
+			"file to read (exactly one of -file, -url, -stdin)",
+			"output json (conflicts with -yaml)",
+			"output yaml (conflicts with -json)",
+			"key file (requires -tls.cert)",
+		} {
+			if !strings.Contains(buf.String(), s) {
+				t.Errorf("expected help to contain %q, got:\n%s", s, buf.String())
+			}
+		}
+	})
+
+	t.Run("adds constraints to a flagset", func(t *testing.T) {
+		type Example struct {
+			A bool
+			B bool
+			C bool
+		}
+		var example Example
+		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+		if err := AddConstraints(fs, AtMostOne("a", "b"), Requires("c", "a")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := fs.Parse([]string{"-a", "-b", "-c"}); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		if err := Check(fs); !errors.Is(err, ErrConflictingFlags) {
+			t.Errorf("expected ErrConflictingFlags, got %v", err)
+		}
+		fs.VisitAll(func(f *flag.Flag) { Reset(f.Value) })
+		if err := fs.Parse([]string{"-c"}); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		if err := Check(fs); !errors.Is(err, ErrMissingRequiredFlag) {
+			t.Errorf("expected ErrMissingRequiredFlag, got %v", err)
+		}
+
+		if err := AddConstraints(fs, ExactlyOne("a", "missing")); err == nil || !strings.Contains(err.Error(), "-missing") {
+			t.Errorf("expected error for unknown flag, got %v", err)
+		}
+		fs.Bool("plain", false, "")
+		if err := AddConstraints(fs, ExactlyOne("a", "plain")); err == nil {
+			t.Errorf("expected error for flag not registered by StructVar")
+		}
+	})
+}
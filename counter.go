@@ -0,0 +1,91 @@
+package flage
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// Counter is an int that's incremented each time its flag is given without a
+// value, eg - "-v -v -v" sets it to 3. It's useful for verbosity levels.
+// A value can still be given explicitly, eg - "-v=3".
+//
+// Example:
+//
+//	var verbosity flage.Counter
+//	flag.Var(&verbosity, "v", "verbosity, can be repeated")
+type Counter int
+
+// String returns the count
+func (c *Counter) String() string {
+	if c == nil {
+		return "0"
+	}
+	return strconv.Itoa(int(*c))
+}
+
+// Set increments the count for "true", which is what the flag package passes
+// when the flag is given without a value. "false" or an empty string reset it
+// to 0, and any other value sets the count.
+func (c *Counter) Set(value string) error {
+	switch value {
+	case "true":
+		*c++
+	case "false", "":
+		*c = 0
+	default:
+		v, err := strconv.ParseInt(value, 0, strconv.IntSize)
+		if err != nil {
+			return numError(err)
+		}
+		*c = Counter(v)
+	}
+	return nil
+}
+
+// Get returns the count as an int
+func (c *Counter) Get() any { return int(*c) }
+
+// IsBoolFlag allows the flag to be given without a value
+func (c *Counter) IsBoolFlag() bool { return true }
+
+// counterValue is used by StructVar for integer fields with the counter option.
+// It behaves like Counter, but for any integer type.
+type counterValue struct {
+	*scalarValue
+}
+
+func (c *counterValue) IsBoolFlag() bool { return true }
+
+func (c *counterValue) Set(s string) error {
+	switch s {
+	case "true":
+		if v := c.ptr; v.CanInt() {
+			i := v.Int() + 1
+			if i < v.Int() || v.OverflowInt(i) {
+				return errRange
+			}
+			v.SetInt(i)
+		} else {
+			u := v.Uint() + 1
+			if u == 0 || v.OverflowUint(u) {
+				return errRange
+			}
+			v.SetUint(u)
+		}
+		return nil
+	case "false":
+		c.ptr.Set(reflect.Zero(c.ptr.Type()))
+		return nil
+	}
+	return c.scalarValue.Set(s)
+}
+
+// isIntegerKind returns true if k is a signed or unsigned integer
+func isIntegerKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package flage
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestCounter(t *testing.T) {
+	var c Counter
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(&c, "v", "verbosity")
+	if err := fs.Parse([]string{"-v", "-v", "-v"}); err != nil {
+		t.Fatalf("failed to parse flags: %s", err.Error())
+	}
+	if c != 3 {
+		t.Errorf("expected 3, got %d", c)
+	}
+	if err := fs.Parse([]string{"-v=5", "-v"}); err != nil {
+		t.Fatalf("failed to parse flags: %s", err.Error())
+	}
+	if c != 6 {
+		t.Errorf("expected 6, got %d", c)
+	}
+	Reset(&c)
+	if c != 0 {
+		t.Errorf("expected reset to 0, got %d", c)
+	}
+	if err := c.Set("lots"); err == nil {
+		t.Errorf("expected error for invalid count")
+	}
+}
+
+func TestStructVarCounterFields(t *testing.T) {
+	type Example struct {
+		Verbose int     `flage:"verbose|v;counter,,verbosity"`
+		Quiet   uint8   `flage:"q;counter,1"`
+		Debug   Counter `flage:"debug,2"`
+	}
+
+	tests := []struct {
+		args     []string
+		expected Example
+	}{
+		{args: nil, expected: Example{Quiet: 1, Debug: 2}},
+		{args: []string{"-v", "-verbose", "-v", "-q", "-debug"}, expected: Example{Verbose: 3, Quiet: 2, Debug: 3}},
+		{args: []string{"-v=5", "-v", "-q=0", "-debug=0"}, expected: Example{Verbose: 6}},
+	}
+	var actual Example
+	fs := FlagSetStruct("test", flag.ContinueOnError, &actual)
+	for _, tc := range tests {
+		// reuse the flagset, like subcommands do
+		fs.VisitAll(func(f *flag.Flag) { Reset(f.Value) })
+		if err := fs.Parse(tc.args); err != nil {
+			t.Fatalf("%v: failed to parse flags: %s", tc.args, err.Error())
+		}
+		if actual != tc.expected {
+			t.Errorf("%v: expected %#v, got %#v", tc.args, tc.expected, actual)
+		}
+	}
+
+	t.Run("converts to command line args", func(t *testing.T) {
+		v := Example{Verbose: 2, Quiet: 1, Debug: 4}
+		if got := strings.Join(CommandString(&v), " "); got != "-verbose=2 -debug=4" {
+			t.Errorf("unexpected args: %s", got)
+		}
+	})
+
+	t.Run("is shown like a bool in help", func(t *testing.T) {
+		var buf bytes.Buffer
+		fs.SetOutput(&buf)
+		fs.Usage()
+		if !strings.Contains(buf.String(), "  -v, -verbose\n") {
+			t.Errorf("expected counter without a value in help, got:\n%s", buf.String())
+		}
+	})
+
+	t.Run("rejects non-integer fields", func(t *testing.T) {
+		var v struct {
+			Name string `flage:"name;counter"`
+		}
+		if err := StructVarE(&v, flag.NewFlagSet("", flag.ContinueOnError)); err == nil {
+			t.Errorf("expected error for counter option on a string")
+		}
+	})
+}
@@ -2,6 +2,7 @@ package flage
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -291,3 +292,51 @@ func (e *EnvMap) String() string {
 func (e *EnvMap) Reset() {
 	*e = make(EnvMap)
 }
+
+// ApplyEnv sets every flag registered by StructVar that has an environment
+// variable (see the flage-env tag) and was not already set to the value found
+// in env. Call it after parsing the command line so that command line flags
+// take precedence over the environment.
+//
+// Like default values in tags, the values of slice and map fields are comma
+// separated, eg - PORTS=80,443 or LABELS=team=infra,env=prod.
+//
+// If fs is nil, then flag.CommandLine is used instead.
+func ApplyEnv(fs *flag.FlagSet, env *Env) error {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	var errs []error
+	visitStructFlags(fs, func(sf *structFlag) {
+		if sf.set || sf.env == "" {
+			return
+		}
+		if v, ok := env.Lookup(sf.env); ok {
+			if err := setFromEnv(sf, v); err != nil && sf.secret {
+				errs = append(errs, fmt.Errorf("invalid value %s for env var %s (flag -%s): %w", secretMask, sf.env, sf.names[0], sf.redact(err, v)))
+			} else if err != nil {
+				errs = append(errs, fmt.Errorf("invalid value %q for env var %s (flag -%s): %w", v, sf.env, sf.names[0], err))
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// setFromEnv sets sf to the value v of its environment variable, splitting the
+// values of slices and maps like the default value in its tag.
+func setFromEnv(sf *structFlag, v string) error {
+	values := []string{v}
+	switch sf.Value.(type) {
+	case *sliceValue, *mapValue:
+		values = strings.Split(v, ",")
+		for i := range values {
+			values[i] = strings.TrimSpace(values[i])
+		}
+	}
+	for _, value := range values {
+		if err := sf.setFrom(value, Origin{Source: EnvVar, Detail: sf.env, Raw: v}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,7 +1,11 @@
 package flage
 
 import (
+	"bytes"
+	"flag"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -300,6 +304,128 @@ func TestEnvLookup(t *testing.T) {
 	}
 }
 
+func TestApplyEnv(t *testing.T) {
+	type Example struct {
+		Port    int    `flage:"port,80" flage-env:"APP_PORT"`
+		Host    string `flage:"host,localhost" flage-env:"APP_HOST"`
+		Verbose bool   `flage-env:"APP_VERBOSE"`
+		Name    string
+	}
+	env := NewEnv(nil, EnvMap{
+		"APP_PORT":    {"8080"},
+		"APP_HOST":    {"example.com"},
+		"APP_VERBOSE": {"true"},
+		"APP_NAME":    {"ignored"},
+	})
+
+	t.Run("falls back to env for unset flags", func(t *testing.T) {
+		var example Example
+		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+		if err := fs.Parse([]string{"-host", "cmdline"}); err != nil {
+			t.Fatalf("failed to parse flags: %s", err)
+		}
+		if err := ApplyEnv(fs, env); err != nil {
+			t.Fatalf("failed to apply env: %s", err)
+		}
+		expected := Example{Port: 8080, Host: "cmdline", Verbose: true}
+		if !reflect.DeepEqual(expected, example) {
+			t.Errorf("expected %#v, got %#v", expected, example)
+		}
+	})
+
+	t.Run("uses prefix for fields without tags", func(t *testing.T) {
+		var example Example
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		if err := StructVarWithOptions(&example, fs, StructOptions{EnvPrefix: "APP_"}); err != nil {
+			t.Fatalf("failed to register flags: %s", err)
+		}
+		if err := fs.Parse(nil); err != nil {
+			t.Fatalf("failed to parse flags: %s", err)
+		}
+		if err := ApplyEnv(fs, env); err != nil {
+			t.Fatalf("failed to apply env: %s", err)
+		}
+		if example.Name != "ignored" {
+			t.Errorf("expected name from APP_NAME, got %q", example.Name)
+		}
+	})
+
+	t.Run("returns errors for invalid values", func(t *testing.T) {
+		var example Example
+		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+		if err := fs.Parse(nil); err != nil {
+			t.Fatalf("failed to parse flags: %s", err)
+		}
+		err := ApplyEnv(fs, NewEnv(nil, EnvMap{"APP_PORT": {"abc"}}))
+		if err == nil || !strings.Contains(err.Error(), "APP_PORT") {
+			t.Errorf("expected error mentioning APP_PORT, got %v", err)
+		}
+	})
+
+	t.Run("splits values of slices", func(t *testing.T) {
+		var example struct {
+			Ports []int    `flage:"port,80" flage-env:"PORTS"`
+			Hosts []string `flage:"host" flage-env:"HOSTS"`
+		}
+		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+		if err := fs.Parse(nil); err != nil {
+			t.Fatalf("failed to parse flags: %s", err)
+		}
+		if err := ApplyEnv(fs, NewEnv(nil, EnvMap{"PORTS": {"3,4"}, "HOSTS": {"a, b"}})); err != nil {
+			t.Fatalf("failed to apply env: %s", err)
+		}
+		if !reflect.DeepEqual(example.Ports, []int{3, 4}) || !reflect.DeepEqual(example.Hosts, []string{"a", "b"}) {
+			t.Errorf("unexpected values: %#v", example)
+		}
+		if origin, _ := Provenance(fs, "port"); origin.Raw != "3,4" {
+			t.Errorf("expected the whole env var in provenance, got %#v", origin)
+		}
+	})
+
+	t.Run("splits pairs of maps", func(t *testing.T) {
+		var example struct {
+			Labels map[string]string `flage:"label" flage-env:"LABELS"`
+		}
+		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+		if err := fs.Parse(nil); err != nil {
+			t.Fatalf("failed to parse flags: %s", err)
+		}
+		if err := ApplyEnv(fs, NewEnv(nil, EnvMap{"LABELS": {"a=1,b=2"}})); err != nil {
+			t.Fatalf("failed to apply env: %s", err)
+		}
+		if expected := map[string]string{"a": "1", "b": "2"}; !reflect.DeepEqual(example.Labels, expected) {
+			t.Errorf("expected %#v, got %#v", expected, example.Labels)
+		}
+	})
+
+	t.Run("shows env var in help", func(t *testing.T) {
+		var example Example
+		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+		var buf bytes.Buffer
+		fs.SetOutput(&buf)
+		fs.PrintDefaults()
+		if !strings.Contains(buf.String(), "[$APP_PORT]") {
+			t.Errorf("expected help to contain env var, got %q", buf.String())
+		}
+	})
+
+	t.Run("applied by command iterator", func(t *testing.T) {
+		type Commands struct {
+			Serve Example `flage-cmd:"serve"`
+		}
+		var cmds Commands
+		fss := NewFlagSetsAndDefsFromStruct(&cmds, flag.ContinueOnError)
+		fss.Env = env
+		it := fss.Parse([]string{"serve", "-port", "9000"})
+		if !it.Next() {
+			t.Fatalf("expected command, got error: %v", it.Err())
+		}
+		if cmds.Serve.Port != 9000 || cmds.Serve.Host != "example.com" {
+			t.Errorf("unexpected values: %#v", cmds.Serve)
+		}
+	})
+}
+
 func containsSubstring(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) && stringContains(s, substr))
 }
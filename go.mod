@@ -4,4 +4,4 @@ go 1.22.0
 
 require github.com/google/shlex v0.0.0-20191202100458-e7afc7fbc510
 
-require golang.org/x/exp v0.0.0-20240604190554-fc45aab8b7f8 // indirect
+require golang.org/x/exp v0.0.0-20240604190554-fc45aab8b7f8
@@ -0,0 +1,184 @@
+package flage
+
+import (
+	"flag"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Defaulter is implemented by structs that compute their own default values,
+// eg - from os.Hostname. StructVar calls Defaults before registering flags.
+//
+// Fields set by Defaults keep their value as the default of their flag, unless
+// the field has a default value in its flage tag. Defaults of nested structs are
+// called before the struct containing them, which can override them.
+type Defaulter interface {
+	Defaults()
+}
+
+// Normalizer is implemented by structs that clean up their values after
+// parsing, eg - trimming whitespace. Check calls Normalize before validating.
+type Normalizer interface {
+	Normalize()
+}
+
+// Validator is implemented by structs that validate their values as a whole.
+// Check calls Validate after the flage-validate constraints of its fields.
+//
+// Validate can return a *ValidationError with only Field set to the name of a
+// struct field (eg - "Port" or "TLS.Cert"), which Check fills in with the name
+// of its flag. Multiple errors can be returned using errors.Join.
+type Validator interface {
+	Validate() error
+}
+
+// structInfo is a struct registered by StructVar, which may be nested in
+// another registered struct.
+type structInfo struct {
+	v       reflect.Value // addressable struct
+	parent  *structInfo
+	variant *variant // optional, see the variant option of StructVar
+}
+
+func (s *structInfo) depth() int {
+	d := 0
+	for p := s.parent; p != nil; p = p.parent {
+		d++
+	}
+	return d
+}
+
+// applyDefaults calls Defaults on rv and all of the nested structs it has that
+// StructVar registers, starting from the innermost ones. Returns true if any
+// Defaults method was called.
+func applyDefaults(rv reflect.Value, types *TypeRegistry) bool {
+	called := false
+	t := rv.Type()
+	for i, n := 0, t.NumField(); i < n; i++ {
+		f := t.Field(i)
+		if !f.IsExported() || parseFieldTag(f, LowerCase).name == "-" {
+			continue
+		}
+		if isNestedStruct(f.Type, types) {
+			called = applyDefaults(rv.Field(i), types) || called
+		}
+	}
+	if d, ok := rv.Addr().Interface().(Defaulter); ok {
+		d.Defaults()
+		called = true
+	}
+	return called
+}
+
+// cloneValue returns a copy of v that doesn't share memory with v, so that
+// setting flags can't modify it.
+func cloneValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		return reflect.AppendSlice(reflect.MakeSlice(v.Type(), 0, v.Len()), v)
+	case reflect.Map:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		m := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m.SetMapIndex(iter.Key(), iter.Value())
+		}
+		return m
+	case reflect.Pointer:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(v.Elem())
+		return p
+	default:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		return c
+	}
+}
+
+// registeredStructs returns the structs registered in fs, with nested structs
+// before the structs containing them.
+func registeredStructs(fs *flag.FlagSet) []*structInfo {
+	var structs []*structInfo
+	seen := make(map[*structInfo]bool)
+	add := func(sf *structFlag) {
+		for s := sf.owner; s != nil && !seen[s]; s = s.parent {
+			seen[s] = true
+			structs = append(structs, s)
+		}
+	}
+	visitStructFlags(fs, add)
+	if a := lookupArgs(fs); a != nil {
+		for _, p := range a.all() {
+			add(p.structFlag)
+		}
+	}
+	sort.SliceStable(structs, func(i, j int) bool { return structs[i].depth() > structs[j].depth() })
+	return structs
+}
+
+// normalizeStructs calls Normalize on every struct registered in fs
+func normalizeStructs(fs *flag.FlagSet) {
+	for _, s := range registeredStructs(fs) {
+		if n, ok := s.v.Addr().Interface().(Normalizer); ok {
+			n.Normalize()
+		}
+	}
+}
+
+// validateStructs calls Validate on every struct registered in fs
+func validateStructs(fs *flag.FlagSet) []error {
+	var errs []error
+	for _, s := range registeredStructs(fs) {
+		v, ok := s.v.Addr().Interface().(Validator)
+		if !ok || s.inactiveVariant() != nil {
+			continue
+		}
+		err := v.Validate()
+		if err == nil {
+			continue
+		}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, err := range joined.Unwrap() {
+				errs = append(errs, resolveValidationError(fs, s, err))
+			}
+		} else {
+			errs = append(errs, resolveValidationError(fs, s, err))
+		}
+	}
+	return errs
+}
+
+// resolveValidationError fills in the flag name of a *ValidationError returned
+// by the Validate method of s.
+func resolveValidationError(fs *flag.FlagSet, s *structInfo, err error) error {
+	ve, ok := err.(*ValidationError)
+	if !ok || ve.Flag != "" || ve.Field == "" {
+		return err
+	}
+	field := s.v
+	for _, name := range strings.Split(ve.Field, ".") {
+		if field.Kind() != reflect.Struct {
+			return err
+		}
+		if field = field.FieldByName(name); !field.IsValid() {
+			return err
+		}
+	}
+	resolved := &ValidationError{Field: s.v.Type().Name() + "." + ve.Field, Err: ve.Err}
+	visitStructFlags(fs, func(sf *structFlag) {
+		if sf.field.Addr().Pointer() == field.Addr().Pointer() && sf.field.Type() == field.Type() {
+			resolved.Flag = sf.names[0]
+			resolved.Field = sf.fieldName
+		}
+	})
+	return resolved
+}
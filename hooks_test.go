@@ -0,0 +1,147 @@
+package flage
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"strings"
+	"testing"
+)
+
+type hookTLS struct {
+	Cert string `flage:"cert"`
+	Key  string `flage:"key"`
+}
+
+func (t *hookTLS) Defaults() { t.Cert = "tls.crt" }
+
+func (t *hookTLS) Validate() error {
+	if t.Key != "" && t.Cert == "" {
+		return &ValidationError{Field: "Cert", Err: errors.New("is required with -tls.key")}
+	}
+	return nil
+}
+
+type hookServer struct {
+	Host   string   `flage:"host,,host to listen on"`
+	Port   int      `flage:"port,8080,port to listen on"`
+	Peers  []string `flage:"peer,,peers to connect to"`
+	Mode   string   `flage:"mode"`
+	TLS    hookTLS  `flage:"tls"`
+	called []string
+}
+
+func (s *hookServer) Defaults() {
+	s.Host = "localhost"
+	s.Port = 1 // ignored, the tag has a default
+	s.Peers = []string{"a", "b"}
+	s.TLS.Cert = "server.crt"
+	s.called = append(s.called, "defaults")
+}
+
+func (s *hookServer) Normalize() {
+	s.Mode = strings.ToLower(strings.TrimSpace(s.Mode))
+	s.called = append(s.called, "normalize")
+}
+
+func (s *hookServer) Validate() error {
+	s.called = append(s.called, "validate")
+	var errs []error
+	if s.Mode != "" && s.Mode != "dev" && s.Mode != "prod" {
+		errs = append(errs, &ValidationError{Field: "Mode", Err: errors.New("must be dev or prod")})
+	}
+	if s.Host == "" {
+		errs = append(errs, errors.New("a host is required"))
+	}
+	if s.TLS.Key == "insecure" {
+		errs = append(errs, &ValidationError{Field: "TLS.Key", Err: errors.New("is insecure")})
+	}
+	return errors.Join(errs...)
+}
+
+func TestStructHooks(t *testing.T) {
+	t.Run("uses values from Defaults as flag defaults", func(t *testing.T) {
+		var s hookServer
+		fs := FlagSetStruct("test", flag.ContinueOnError, &s)
+		if s.Host != "localhost" || s.Port != 8080 || len(s.Peers) != 2 || s.TLS.Cert != "server.crt" {
+			t.Errorf("unexpected defaults: %#v", s)
+		}
+
+		var buf bytes.Buffer
+		fs.SetOutput(&buf)
+		fs.Usage()
+		if !strings.Contains(buf.String(), `host to listen on (default "localhost")`) {
+			t.Errorf("expected default from Defaults in help, got:\n%s", buf.String())
+		}
+
+		if err := fs.Parse([]string{"-host", "example.com", "-peer", "c", "-tls.cert", "x.crt"}); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		if s.Host != "example.com" || strings.Join(s.Peers, ",") != "c" || s.TLS.Cert != "x.crt" {
+			t.Errorf("unexpected values: %#v", s)
+		}
+
+		fs.VisitAll(func(f *flag.Flag) { Reset(f.Value) })
+		if s.Host != "localhost" || strings.Join(s.Peers, ",") != "a,b" || s.TLS.Cert != "server.crt" {
+			t.Errorf("expected reset to restore values from Defaults, got %#v", s)
+		}
+	})
+
+	t.Run("Check calls Normalize and Validate", func(t *testing.T) {
+		var s hookServer
+		fs := FlagSetStruct("test", flag.ContinueOnError, &s)
+		if err := fs.Parse([]string{"-mode", " PROD "}); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		if err := Check(fs); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if s.Mode != "prod" {
+			t.Errorf("expected normalized mode, got %q", s.Mode)
+		}
+		if got := strings.Join(s.called, ","); got != "defaults,normalize,validate" {
+			t.Errorf("unexpected hook order: %s", got)
+		}
+	})
+
+	t.Run("Validate errors name flags", func(t *testing.T) {
+		var s hookServer
+		fs := FlagSetStruct("test", flag.ContinueOnError, &s)
+		if err := fs.Parse([]string{"-mode", "test", "-host", "", "-tls.cert", "", "-tls.key", "insecure"}); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		err := Check(fs)
+		for _, s := range []string{
+			"invalid value for -mode (hookServer.Mode): must be dev or prod",
+			"a host is required",
+			"invalid value for -tls.key (hookTLS.Key): is insecure",
+			"invalid value for -tls.cert (hookTLS.Cert): is required with -tls.key",
+		} {
+			if err == nil || !strings.Contains(err.Error(), s) {
+				t.Errorf("expected error to contain %q, got %v", s, err)
+			}
+		}
+		var ve *ValidationError
+		if !errors.As(err, &ve) || ve.Flag == "" {
+			t.Errorf("expected a resolved *ValidationError, got %#v", ve)
+		}
+	})
+
+	t.Run("calls hooks for commands", func(t *testing.T) {
+		type Commands struct {
+			Serve hookServer `flage-cmd:"serve"`
+		}
+		var cmds Commands
+		fss := NewFlagSetsAndDefsFromStruct(&cmds, flag.ContinueOnError)
+		if cmds.Serve.Host != "localhost" {
+			t.Errorf("expected Defaults to be called, got %#v", cmds.Serve)
+		}
+		it := fss.Parse([]string{"serve", "-mode", "nope"})
+		if it.Next() {
+			t.Fatalf("expected Validate to fail")
+		}
+		if err := it.Err(); err == nil || !strings.Contains(err.Error(), "-mode") {
+			t.Errorf("expected validation error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package flage
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// mapValue is used by StructVar for map fields. Each use of the flag adds a
+// KEY=VALUE pair to the map. The first use of the flag replaces any default
+// values.
+type mapValue struct {
+	ptr      reflect.Value // addressable map
+	key      codec
+	elem     codec
+	defvalue [][2]reflect.Value
+	changed  bool // set since the last Reset
+}
+
+func newMapValue(ptr reflect.Value, key, elem codec, defvalue string) (*mapValue, error) {
+	m := &mapValue{ptr: ptr, key: key, elem: elem}
+	if defvalue != "" {
+		for _, part := range strings.Split(defvalue, ",") {
+			k, v, err := m.parse(strings.TrimSpace(part))
+			if err != nil {
+				return nil, err
+			}
+			m.defvalue = append(m.defvalue, [2]reflect.Value{k, v})
+		}
+	}
+	m.Reset()
+	return m, nil
+}
+
+func (m *mapValue) parse(s string) (reflect.Value, reflect.Value, error) {
+	rawKey, rawValue, found := strings.Cut(s, "=")
+	if !found {
+		return reflect.Value{}, reflect.Value{}, fmt.Errorf("expected KEY=VALUE, got %q", s)
+	}
+	k, err := m.key.parse(rawKey)
+	if err != nil {
+		return reflect.Value{}, reflect.Value{}, err
+	}
+	v, err := m.elem.parse(rawValue)
+	if err != nil {
+		return reflect.Value{}, reflect.Value{}, err
+	}
+	return k, v, nil
+}
+
+// pairs returns the formatted KEY=VALUE entries of the map, sorted by key
+func (m *mapValue) pairs() []string {
+	return formatMap(m.ptr, m.key, m.elem)
+}
+
+func formatMap(rv reflect.Value, key, elem codec) []string {
+	entries := make([][2]string, 0, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		entries = append(entries, [2]string{key.format(iter.Key()), elem.format(iter.Value())})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i][0] < entries[j][0] })
+	pairs := make([]string, len(entries))
+	for i, e := range entries {
+		pairs[i] = e[0] + "=" + e[1]
+	}
+	return pairs
+}
+
+// String returns a string with ", " joined between each KEY=VALUE, sorted by key
+func (m *mapValue) String() string {
+	if m == nil || !m.ptr.IsValid() {
+		return ""
+	}
+	return strings.Join(m.pairs(), ", ")
+}
+
+// Set adds a KEY=VALUE pair to the map, replacing the default values on first use.
+func (m *mapValue) Set(value string) error {
+	k, v, err := m.parse(value)
+	if err != nil {
+		return err
+	}
+	if !m.changed {
+		m.ptr.Set(reflect.MakeMap(m.ptr.Type()))
+		m.changed = true
+	}
+	m.ptr.SetMapIndex(k, v)
+	return nil
+}
+
+func (m *mapValue) Get() any { return m.ptr.Interface() }
+
+// Reset creates a new map containing the default values
+func (m *mapValue) Reset() {
+	dict := reflect.MakeMapWithSize(m.ptr.Type(), len(m.defvalue))
+	for _, kv := range m.defvalue {
+		dict.SetMapIndex(kv[0], kv[1])
+	}
+	m.ptr.Set(dict)
+	m.changed = false
+}
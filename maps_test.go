@@ -0,0 +1,54 @@
+package flage
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestStructVarMapFields(t *testing.T) {
+	type Example struct {
+		Labels map[string]string `flage:"label"`
+		Limits map[string]int    `flage:"limit,'cpu=1,mem=2'"`
+	}
+
+	var example Example
+	fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+	if !reflect.DeepEqual(example.Limits, map[string]int{"cpu": 1, "mem": 2}) {
+		t.Errorf("expected default values, got %#v", example.Limits)
+	}
+
+	err := fs.Parse([]string{
+		"-label", "team=infra",
+		"-label", "env=prod",
+		"-limit", "cpu=4",
+	})
+	if err != nil {
+		t.Fatalf("failed to parse flags: %s", err.Error())
+	}
+	expected := Example{
+		Labels: map[string]string{"team": "infra", "env": "prod"},
+		Limits: map[string]int{"cpu": 4},
+	}
+	if !reflect.DeepEqual(expected, example) {
+		t.Errorf("expected %#v, got %#v", expected, example)
+	}
+	if s := fs.Lookup("label").Value.String(); s != "env=prod, team=infra" {
+		t.Errorf("expected String() to be sorted by key, got %q", s)
+	}
+
+	fs.VisitAll(func(f *flag.Flag) { Reset(f.Value) })
+	expected = Example{
+		Labels: map[string]string{},
+		Limits: map[string]int{"cpu": 1, "mem": 2},
+	}
+	if !reflect.DeepEqual(expected, example) {
+		t.Errorf("expected %#v, got %#v", expected, example)
+	}
+
+	for _, arg := range []string{"cpu", "cpu=abc"} {
+		if err := fs.Parse([]string{"-limit", arg}); err == nil {
+			t.Errorf("expected error for %q", arg)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package flage
+
+import (
+	"reflect"
+	"strings"
+)
+
+// metavar returns the placeholder for the value of a flag in help, eg -
+// "DURATION" in "-timeout DURATION". rules is the flage-validate tag of the
+// field, since choices and paths describe the value better than its type.
+func metavar(t reflect.Type, rules string) string {
+	for _, rule := range splitRules(rules) {
+		if choices, ok := strings.CutPrefix(rule, "oneof="); ok {
+			return "{" + choices + "}"
+		} else if rule == "file-exists" {
+			return "PATH"
+		}
+	}
+	return typeMetavar(t)
+}
+
+// typeMetavar returns the placeholder for values of type t, eg - "INT"
+func typeMetavar(t reflect.Type) string {
+	if reflect.PointerTo(t).Implements(flagValueType) {
+		return "VALUE"
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeMetavar(t.Elem())
+	case reflect.Map:
+		return "KEY=VALUE"
+	case reflect.Slice:
+		// each use of the flag is an element
+		return typeMetavar(t.Elem())
+	}
+	if t.PkgPath() != "" {
+		// named types describe their values, eg - "type Port uint16" or time.Duration
+		name, _, _ := strings.Cut(t.Name(), "[")
+		return strings.ToUpper(name)
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "INT"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "UINT"
+	case reflect.Float32, reflect.Float64:
+		return "FLOAT"
+	case reflect.String:
+		return "STRING"
+	}
+	return "VALUE"
+}
@@ -0,0 +1,51 @@
+package flage
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NamingStrategy converts the name of a struct field into the name of a flag
+// or command, eg - "MaxRetries" into "max-retries". It's only used for fields
+// without a name in their tag. See StructOptions.Naming.
+type NamingStrategy func(field string) string
+
+// LowerCase names "MaxRetries" as "maxretries". It's the default strategy.
+func LowerCase(field string) string { return strings.ToLower(field) }
+
+// KebabCase names "MaxRetries" as "max-retries"
+func KebabCase(field string) string { return strings.Join(splitWords(field), "-") }
+
+// SnakeCase names "MaxRetries" as "max_retries"
+func SnakeCase(field string) string { return strings.Join(splitWords(field), "_") }
+
+// splitWords splits an identifier into lower case words at changes of case
+// and underscores, eg - "HTTPServer_URL" into "http", "server" and "url".
+func splitWords(s string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(s)
+	for i, r := range runes {
+		if r == '_' {
+			if len(word) > 0 {
+				words = append(words, string(word))
+				word = nil
+			}
+			continue
+		}
+		if unicode.IsUpper(r) && len(word) > 0 {
+			// a new word starts after lower case letters or digits, or at the
+			// last upper case letter of an acronym, eg - the "S" of "HTTPServer"
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if !unicode.IsUpper(runes[i-1]) || nextLower {
+				words = append(words, string(word))
+				word = nil
+			}
+		}
+		word = append(word, unicode.ToLower(r))
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
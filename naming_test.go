@@ -0,0 +1,99 @@
+package flage
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNamingStrategies(t *testing.T) {
+	tests := []struct {
+		field, kebab, snake string
+	}{
+		{"MaxRetries", "max-retries", "max_retries"},
+		{"HTTPServer", "http-server", "http_server"},
+		{"UserID", "user-id", "user_id"},
+		{"Max_Retries", "max-retries", "max_retries"},
+		{"V2Endpoint", "v2-endpoint", "v2_endpoint"},
+		{"Name", "name", "name"},
+	}
+	for _, tc := range tests {
+		if got := KebabCase(tc.field); got != tc.kebab {
+			t.Errorf("KebabCase(%q): expected %q, got %q", tc.field, tc.kebab, got)
+		}
+		if got := SnakeCase(tc.field); got != tc.snake {
+			t.Errorf("SnakeCase(%q): expected %q, got %q", tc.field, tc.snake, got)
+		}
+	}
+
+	type DB struct {
+		MaxConns int
+	}
+	type Example struct {
+		MaxRetries int
+		Output     string `flage:"out"`
+		DB         DB
+	}
+
+	t.Run("names flags and env vars", func(t *testing.T) {
+		var actual Example
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		if err := StructVarWithOptions(&actual, fs, StructOptions{Naming: KebabCase, EnvPrefix: "APP_"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, name := range []string{"max-retries", "out", "db.max-conns"} {
+			if fs.Lookup(name) == nil {
+				t.Errorf("expected flag -%s to be defined", name)
+			}
+		}
+		if err := ApplyEnv(fs, NewEnv(nil, EnvMap{"APP_MAX_RETRIES": {"3"}, "APP_DB_MAX_CONNS": {"5"}})); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if actual.MaxRetries != 3 || actual.DB.MaxConns != 5 {
+			t.Errorf("unexpected values: %#v", actual)
+		}
+	})
+
+	t.Run("uses a custom strategy", func(t *testing.T) {
+		var actual Example
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		upper := func(field string) string { return strings.ToUpper(field) }
+		if err := StructVarWithOptions(&actual, fs, StructOptions{Naming: upper}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if fs.Lookup("MAXRETRIES") == nil || fs.Lookup("out") == nil {
+			t.Errorf("expected flags named by the strategy")
+		}
+	})
+
+	t.Run("converts to command line args", func(t *testing.T) {
+		v := Example{MaxRetries: 2, DB: DB{MaxConns: 4}}
+		got := CommandStringWithOptions(&v, StructOptions{Naming: SnakeCase})
+		if expected := []string{"-max_retries", "2", "-db.max_conns", "4"}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("names commands", func(t *testing.T) {
+		type Commands struct {
+			AddUser    struct{ UserName string }
+			RemoveUser struct{} `flage-cmd:"rm"`
+		}
+		var cmds Commands
+		fss, err := NewFlagSetsAndDefsFromStructWithOptions(&cmds, flag.ContinueOnError, StructOptions{Naming: KebabCase})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var names []string
+		for _, fs := range fss.Sets {
+			names = append(names, fs.Name())
+		}
+		if expected := []string{"add-user", "rm"}; !reflect.DeepEqual(names, expected) {
+			t.Errorf("expected commands %v, got %v", expected, names)
+		}
+		if fss.Sets[0].Lookup("user-name") == nil {
+			t.Errorf("expected -user-name to be defined")
+		}
+	})
+}
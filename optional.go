@@ -0,0 +1,72 @@
+package flage
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// OptionalBool is a bool flag that can also be unset, like sql.NullBool. It's
+// useful for settings where unset means the value comes from somewhere else,
+// like a config file.
+//
+// Like a bool, the flag can be given without a value. Setting it to an empty
+// string unsets it.
+type OptionalBool struct {
+	Value bool
+	Valid bool // true if Value was set
+}
+
+// SomeBool returns an OptionalBool that's set to v
+func SomeBool(v bool) OptionalBool { return OptionalBool{Value: v, Valid: true} }
+
+// Or returns the value if it's set, otherwise fallback
+func (b OptionalBool) Or(fallback bool) bool {
+	if b.Valid {
+		return b.Value
+	}
+	return fallback
+}
+
+// String returns "true" or "false", or an empty string if it's unset
+func (b *OptionalBool) String() string {
+	if b == nil || !b.Valid {
+		return ""
+	}
+	return strconv.FormatBool(b.Value)
+}
+
+// Set parses a bool, or unsets it for an empty string
+func (b *OptionalBool) Set(s string) error {
+	if s == "" {
+		*b = OptionalBool{}
+		return nil
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return errParse
+	}
+	*b = SomeBool(v)
+	return nil
+}
+
+// Get returns the bool value, or nil if it's unset
+func (b *OptionalBool) Get() any {
+	if !b.Valid {
+		return nil
+	}
+	return b.Value
+}
+
+// IsBoolFlag allows the flag to be given without a value
+func (b *OptionalBool) IsBoolFlag() bool { return true }
+
+var optionalBoolType = reflect.TypeOf(OptionalBool{})
+
+// isBoolType returns true if t is set like a bool, so that StructVar can
+// register a "no-" flag for it.
+func isBoolType(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		return t.Elem().Kind() == reflect.Bool
+	}
+	return t.Kind() == reflect.Bool || t == optionalBoolType
+}
@@ -0,0 +1,129 @@
+package flage
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestNegatableBools(t *testing.T) {
+	type DB struct {
+		TLS bool `flage:"tls,true"`
+	}
+	type Example struct {
+		Color   bool         `flage:"color,true,colorize output"`
+		Verbose bool         `flage:"verbose|v"`
+		Quiet   bool         `flage:"q"`
+		Cache   *bool        `flage:"cache"`
+		Pager   OptionalBool `flage:"pager,,use a pager"`
+		DB      DB           `flage:"db"`
+	}
+
+	tests := []struct {
+		args  []string
+		check func(e Example) bool
+	}{
+		{args: nil, check: func(e Example) bool { return e.Color && e.DB.TLS && e.Cache == nil && !e.Pager.Valid }},
+		{args: []string{"-no-color", "-db.no-tls"}, check: func(e Example) bool { return !e.Color && !e.DB.TLS }},
+		{args: []string{"-no-color=false"}, check: func(e Example) bool { return e.Color }},
+		{args: []string{"-no-cache", "-no-pager"}, check: func(e Example) bool { return e.Cache != nil && !*e.Cache && e.Pager == SomeBool(false) }},
+		{args: []string{"-pager", "-verbose", "-no-verbose"}, check: func(e Example) bool { return e.Pager == SomeBool(true) && !e.Verbose }},
+	}
+	var actual Example
+	fs := FlagSetStruct("test", flag.ContinueOnError, &actual)
+	for _, tc := range tests {
+		fs.VisitAll(func(f *flag.Flag) { Reset(f.Value) })
+		if err := fs.Parse(tc.args); err != nil {
+			t.Fatalf("%v: failed to parse flags: %s", tc.args, err.Error())
+		}
+		if !tc.check(actual) {
+			t.Errorf("%v: unexpected values: %#v", tc.args, actual)
+		}
+	}
+	if fs.Lookup("no-q") != nil || fs.Lookup("no-v") != nil {
+		t.Errorf("expected no negation for single letter flags")
+	}
+
+	t.Run("shows the pair in help", func(t *testing.T) {
+		var buf bytes.Buffer
+		fs.SetOutput(&buf)
+		fs.Usage()
+		for _, s := range []string{
+			"  -[no-]color\n    \tcolorize output (default true)\n",
+			"  -v, -[no-]verbose\n",
+			"  -[no-]pager\n    \tuse a pager\n",
+			"  -db.[no-]tls\n",
+			"  -q\t",
+		} {
+			if !strings.Contains(buf.String(), s) {
+				t.Errorf("expected help to contain %q, got:\n%s", s, buf.String())
+			}
+		}
+		if strings.Contains(buf.String(), "-no-color") {
+			t.Errorf("expected negations to be shown with their flag, got:\n%s", buf.String())
+		}
+	})
+
+	t.Run("converts to command line args", func(t *testing.T) {
+		no := false
+		v := Example{Color: false, Cache: &no, Pager: SomeBool(false), DB: DB{TLS: true}}
+		if got := strings.Join(CommandString(&v), " "); got != "-no-color -no-cache -no-pager" {
+			t.Errorf("unexpected args: %s", got)
+		}
+	})
+
+	t.Run("negates values from config files", func(t *testing.T) {
+		fs.VisitAll(func(f *flag.Flag) { Reset(f.Value) })
+		if err := applyConfig(fs, []string{"-no-color", "-db.no-tls=false"}, "app.conf"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if actual.Color || !actual.DB.TLS {
+			t.Errorf("unexpected values: %#v", actual)
+		}
+		if origin, _ := Provenance(fs, "color"); origin.Source != ConfigFile {
+			t.Errorf("expected color to come from the config file, got %v", origin)
+		}
+	})
+
+	t.Run("reports the negation in provenance", func(t *testing.T) {
+		fs.VisitAll(func(f *flag.Flag) { Reset(f.Value) })
+		if err := fs.Parse([]string{"-no-color"}); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		if origin, _ := Provenance(fs, "color"); origin.String() != "command line -no-color" {
+			t.Errorf("unexpected origin: %v", origin)
+		}
+	})
+
+	t.Run("keeps explicit no- fields", func(t *testing.T) {
+		var v struct {
+			Cache   bool `flage:"cache"`
+			NoCache bool `flage:"no-cache"`
+		}
+		fs := FlagSetStruct("test", flag.ContinueOnError, &v)
+		if err := fs.Parse([]string{"-no-cache"}); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		if v.Cache || !v.NoCache {
+			t.Errorf("unexpected values: %#v", v)
+		}
+	})
+}
+
+func TestOptionalBool(t *testing.T) {
+	var b OptionalBool
+	if b.Or(true) != true || b.String() != "" || b.Get() != nil {
+		t.Errorf("expected unset value, got %#v", b)
+	}
+	if err := b.Set("false"); err != nil || b != SomeBool(false) || b.Or(true) {
+		t.Errorf("expected false, got %#v (err: %v)", b, err)
+	}
+	if err := b.Set("nope"); err == nil {
+		t.Errorf("expected error for invalid bool")
+	}
+	Reset(&b)
+	if b.Valid {
+		t.Errorf("expected reset to unset, got %#v", b)
+	}
+}
@@ -0,0 +1,305 @@
+package flage
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	flagePosTag  = "flage-pos"
+	flageRestTag = "flage-rest"
+)
+
+var (
+	// ErrMissingArgument is returned by Check for each required positional
+	// argument that was not given.
+	ErrMissingArgument = errors.New("missing argument")
+	// ErrUnexpectedArgument is returned by Check when there are more
+	// positional arguments than the struct has fields for.
+	ErrUnexpectedArgument = errors.New("unexpected argument")
+)
+
+// positionalArg is a struct field bound to a positional argument
+type positionalArg struct {
+	*structFlag
+	index int // -1 for the rest of the arguments
+	doc   string
+}
+
+func (p *positionalArg) name() string { return p.names[0] }
+
+// positionalArgs stores the positional arguments of structs registered in a
+// flagset.
+type positionalArgs struct {
+	args  []*positionalArg // sorted by index
+	rest  *positionalArg   // optional
+	bound []string         // the arguments last set by bind
+}
+
+// all returns the positional arguments, including the rest of the arguments
+func (a *positionalArgs) all() []*positionalArg {
+	if a.rest == nil {
+		return a.args
+	}
+	return append(a.args[:len(a.args):len(a.args)], a.rest)
+}
+
+// registeredArgs holds the positional arguments of each flagset that has any,
+// since the flag package would list anything stored in the flagset as a flag.
+// Flagsets that are thrown away by this package are removed with forgetArgs.
+var registeredArgs = struct {
+	sync.Mutex
+	sets map[*flag.FlagSet]*positionalArgs
+}{sets: make(map[*flag.FlagSet]*positionalArgs)}
+
+// lookupArgs returns the positional arguments registered in fs, or nil
+func lookupArgs(fs *flag.FlagSet) *positionalArgs {
+	registeredArgs.Lock()
+	defer registeredArgs.Unlock()
+	return registeredArgs.sets[fs]
+}
+
+// forgetArgs removes the positional arguments registered in fs, so that fs can
+// be garbage collected
+func forgetArgs(fs *flag.FlagSet) {
+	registeredArgs.Lock()
+	defer registeredArgs.Unlock()
+	delete(registeredArgs.sets, fs)
+}
+
+// addPositional registers a positional argument for a struct field
+func addPositional(fs *flag.FlagSet, p *positionalArg) error {
+	registeredArgs.Lock()
+	a := registeredArgs.sets[fs]
+	if a == nil {
+		a = &positionalArgs{}
+		registeredArgs.sets[fs] = a
+	}
+	registeredArgs.Unlock()
+	if p.index < 0 {
+		if a.rest != nil {
+			return fmt.Errorf("has a duplicate %s tag, %s is already used", flageRestTag, a.rest.fieldName)
+		}
+		a.rest = p
+		return nil
+	}
+	for _, other := range a.args {
+		if other.index == p.index {
+			return fmt.Errorf("has a duplicate %s index %d, already used by %s", flagePosTag, p.index, other.fieldName)
+		}
+	}
+	a.args = append(a.args, p)
+	sort.Slice(a.args, func(i, j int) bool { return a.args[i].index < a.args[j].index })
+	return nil
+}
+
+// checkPositional returns an error if the positional arguments in fs have
+// gaps in their indexes, or if required ones follow optional ones.
+func checkPositional(fs *flag.FlagSet) error {
+	a := lookupArgs(fs)
+	if a == nil {
+		return nil
+	}
+	optional := ""
+	for i, p := range a.args {
+		if p.index != i {
+			return fmt.Errorf("%s has %s index %d, but there's no argument at index %d", p.fieldName, flagePosTag, p.index, i)
+		}
+		if p.required && optional != "" {
+			return fmt.Errorf("%s is a required argument after optional argument %s", p.fieldName, optional)
+		}
+		if !p.required {
+			optional = p.fieldName
+		}
+	}
+	return nil
+}
+
+// positionalVar registers the struct field of sf as a positional argument
+// from its flage-pos tag ("INDEX,NAME,DOC") or flage-rest tag ("NAME,DOC").
+// NAME can be followed by semicolon separated options, like the flage tag.
+func positionalVar(fs *flag.FlagSet, sf *structFlag, f reflect.StructField, defaults bool, numBase int, opts StructOptions) error {
+	p := &positionalArg{structFlag: sf, index: -1}
+	raw, hasIndex := f.Tag.Lookup(flagePosTag)
+	if hasIndex {
+		if _, ok := f.Tag.Lookup(flageRestTag); ok {
+			return fmt.Errorf("can't have both %s and %s tags", flagePosTag, flageRestTag)
+		}
+		index, rest, _ := strings.Cut(raw, ",")
+		var err error
+		if p.index, err = strconv.Atoi(strings.TrimSpace(index)); err != nil || p.index < 0 {
+			return fmt.Errorf("has an invalid %s index: %q", flagePosTag, index)
+		}
+		raw = rest
+	} else {
+		raw = f.Tag.Get(flageRestTag)
+		if f.Type.Kind() != reflect.Slice {
+			return fmt.Errorf("has a %s tag, but is not a slice", flageRestTag)
+		}
+	}
+	name, doc, _ := strings.Cut(raw, ",")
+	name, rawOpts, _ := strings.Cut(name, ";")
+	if name = strings.TrimSpace(name); name == "" {
+		name = opts.Naming(f.Name)
+	}
+	p.names = []string{name}
+	p.doc = strings.TrimSpace(doc)
+	p.required = hasIndex
+	for _, opt := range strings.Split(rawOpts, ";") {
+		switch opt = strings.TrimSpace(opt); opt {
+		case "":
+		case "optional":
+			p.required = false
+		case "required":
+			p.required = true
+		case "secret":
+			sf.secret = true
+		default:
+			return fmt.Errorf("has an unknown option %q", opt)
+		}
+	}
+
+	var preset reflect.Value
+	if defaults && !sf.field.IsZero() {
+		preset = cloneValue(sf.field)
+	}
+	var err error
+	if sf.Value, _, err = newFieldValue(sf.field, "", p.doc, numBase, opts.Types); err != nil {
+		return err
+	}
+	sf.preset = preset
+	sf.zero = zeroString(sf)
+	if preset.IsValid() {
+		sf.field.Set(cloneValue(preset))
+	}
+	if raw := f.Tag.Get(flageValidateTag); raw != "" {
+		if sf.validators, err = parseValidators(raw, f.Type, numBase, opts.Types); err != nil {
+			return fmt.Errorf("has an invalid %s tag: %w", flageValidateTag, err)
+		}
+	}
+	return addPositional(fs, p)
+}
+
+// bind resets the positional arguments and sets them from args, returning the
+// arguments that are left over. A "--" after the positional arguments is
+// consumed, so that another command can follow.
+func (a *positionalArgs) bind(args []string) ([]string, error) {
+	for _, p := range a.all() {
+		p.Reset()
+	}
+	a.bound = args
+	var errs []error
+	set := func(p *positionalArg, arg string) {
+		if err := p.setFrom(arg, Origin{Source: CommandLine, Detail: p.name(), Raw: arg}); err != nil {
+			if p.secret {
+				errs = append(errs, fmt.Errorf("invalid value %s for argument %s: %w", secretMask, p.name(), p.redact(err, arg)))
+			} else {
+				errs = append(errs, fmt.Errorf("invalid value %q for argument %s: %w", arg, p.name(), err))
+			}
+		}
+	}
+	for _, p := range a.args {
+		if len(args) == 0 || args[0] == "--" {
+			break
+		}
+		set(p, args[0])
+		args = args[1:]
+	}
+	if a.rest != nil {
+		for len(args) > 0 && args[0] != "--" {
+			set(a.rest, args[0])
+			args = args[1:]
+		}
+	}
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	return args, errors.Join(errs...)
+}
+
+// checkArgs binds the positional arguments of fs to fs.Args(), unless a
+// CommandIterator already did, and returns errors for missing, invalid or
+// unexpected arguments.
+func checkArgs(fs *flag.FlagSet) []error {
+	a := lookupArgs(fs)
+	if a == nil {
+		return nil
+	}
+	var errs []error
+	if !sameArgs(a.bound, fs.Args()) {
+		left, err := a.bind(fs.Args())
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if len(left) > 0 {
+			errs = append(errs, fmt.Errorf("%w: %q", ErrUnexpectedArgument, left[0]))
+		}
+	}
+	for _, p := range a.all() {
+		if p.required && !p.set {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrMissingArgument, p.name()))
+			continue
+		}
+		for _, validate := range p.validators {
+			if err := validate(p.field); err != nil {
+				errs = append(errs, &ValidationError{Field: p.fieldName + " (argument " + p.name() + ")", Err: p.redact(err)})
+			}
+		}
+	}
+	return errs
+}
+
+// sameArgs returns true if a and b are the same slice, eg - the arguments
+// left after the same call to flag.FlagSet.Parse
+func sameArgs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	return len(a) == 0 || &a[0] == &b[0]
+}
+
+// synopsis describes the arguments of fs, eg - "[flags] <source> [targets...]"
+func synopsis(fs *flag.FlagSet) string {
+	var b strings.Builder
+	b.WriteString("[flags]")
+	a := lookupArgs(fs)
+	if a == nil {
+		return b.String()
+	}
+	for _, p := range a.args {
+		if p.required {
+			fmt.Fprintf(&b, " <%s>", p.name())
+		} else {
+			fmt.Fprintf(&b, " [%s]", p.name())
+		}
+	}
+	if a.rest != nil {
+		if a.rest.required {
+			fmt.Fprintf(&b, " <%s>...", a.rest.name())
+		} else {
+			fmt.Fprintf(&b, " [%s...]", a.rest.name())
+		}
+	}
+	return b.String()
+}
+
+// printArguments prints the docs of the positional arguments in fs, like
+// PrintDefaults does for flags. Returns false if fs has no positional
+// arguments.
+func printArguments(w io.Writer, fs *flag.FlagSet) bool {
+	a := lookupArgs(fs)
+	if a == nil {
+		return false
+	}
+	for _, p := range a.all() {
+		fmt.Fprintf(w, "  %s\n    \t%s\n", p.name(), p.doc)
+	}
+	return true
+}
@@ -0,0 +1,196 @@
+package flage
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type deployArgs struct {
+	Force   bool     `flage:"force,,overwrite existing files"`
+	Source  string   `flage-pos:"0,source,file to read"`
+	Retries int      `flage-pos:"1,retries;optional,times to retry"`
+	Targets []string `flage-rest:"targets,hosts to deploy to"`
+}
+
+func TestPositionalArgs(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected deployArgs
+		err      error
+	}{
+		{args: []string{"a.txt"}, expected: deployArgs{Source: "a.txt", Targets: []string{}}},
+		{args: []string{"-force", "a.txt", "3", "x", "y"}, expected: deployArgs{Force: true, Source: "a.txt", Retries: 3, Targets: []string{"x", "y"}}},
+		{args: []string{"a.txt", "--", "-x"}, err: ErrUnexpectedArgument},
+		{args: nil, err: ErrMissingArgument},
+	}
+	for _, tc := range tests {
+		var actual deployArgs
+		fs := FlagSetStruct("deploy", flag.ContinueOnError, &actual)
+		if err := fs.Parse(tc.args); err != nil {
+			t.Fatalf("%v: failed to parse flags: %s", tc.args, err.Error())
+		}
+		err := Check(fs)
+		if tc.err != nil {
+			if !errors.Is(err, tc.err) {
+				t.Errorf("%v: expected %v, got %v", tc.args, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.args, err)
+		}
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%v: expected %#v, got %#v", tc.args, tc.expected, actual)
+		}
+	}
+
+	t.Run("reports invalid values", func(t *testing.T) {
+		var actual deployArgs
+		fs := FlagSetStruct("deploy", flag.ContinueOnError, &actual)
+		if err := fs.Parse([]string{"a.txt", "many"}); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		if err := Check(fs); err == nil || !strings.Contains(err.Error(), `invalid value "many" for argument retries`) {
+			t.Errorf("expected invalid value error, got %v", err)
+		}
+	})
+
+	t.Run("shows arguments in help", func(t *testing.T) {
+		var actual deployArgs
+		fs := FlagSetStruct("deploy", flag.ContinueOnError, &actual)
+		var buf bytes.Buffer
+		fs.SetOutput(&buf)
+		fs.Usage()
+		expected := "Usage: deploy [flags] <source> [retries] [targets...]\n" +
+			"\n" +
+			"Arguments:\n" +
+			"  source\n    \tfile to read\n" +
+			"  retries\n    \ttimes to retry\n" +
+			"  targets\n    \thosts to deploy to\n" +
+			"\n" +
+			"Flags:\n" +
+			"  -[no-]force\n    \toverwrite existing files\n"
+		if buf.String() != expected {
+			t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+		}
+	})
+
+	t.Run("keeps arguments out of the flags", func(t *testing.T) {
+		var actual deployArgs
+		fs := FlagSetStruct("deploy", flag.ContinueOnError, &actual)
+		fs.VisitAll(func(f *flag.Flag) {
+			if f.Name == "" {
+				t.Errorf("expected no flag without a name, got %#v", f)
+			}
+		})
+		var buf bytes.Buffer
+		fs.SetOutput(&buf)
+		fs.PrintDefaults()
+		if strings.Contains(buf.String(), "positional") || strings.Contains(buf.String(), "  - ") {
+			t.Errorf("expected only flags, got:\n%s", buf.String())
+		}
+
+		for _, args := range [][]string{{"a.txt", "1", "x"}, {"b.txt", "2", "y"}} {
+			if err := fs.Parse(args); err != nil {
+				t.Fatalf("failed to parse flags: %s", err.Error())
+			}
+			if err := Check(fs); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}
+		if expected := (deployArgs{Source: "b.txt", Retries: 2, Targets: []string{"y"}}); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("expected arguments to be set again, got %#v", actual)
+		}
+	})
+
+	t.Run("leaves arguments after -- for the next command", func(t *testing.T) {
+		type Commands struct {
+			Deploy deployArgs `flage-cmd:"deploy"`
+			Status struct {
+				Verbose bool `flage:"v"`
+			} `flage-cmd:"status"`
+		}
+		var cmds Commands
+		fss := NewFlagSetsAndDefsFromStruct(&cmds, flag.ContinueOnError)
+		it := fss.Parse([]string{"deploy", "a.txt", "1", "x", "--", "status", "-v"})
+		var names []string
+		for it.Next() {
+			names = append(names, it.FlagDef().Name)
+		}
+		if err := it.Err(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if strings.Join(names, ",") != "deploy,status" || cmds.Deploy.Source != "a.txt" || len(cmds.Deploy.Targets) != 1 || !cmds.Status.Verbose {
+			t.Errorf("unexpected commands %v: %#v", names, cmds)
+		}
+
+		it = fss.Parse([]string{"deploy", "-force"})
+		if it.Next() || !errors.Is(it.Err(), ErrMissingArgument) {
+			t.Errorf("expected ErrMissingArgument, got %v", it.Err())
+		}
+	})
+
+	t.Run("converts to command line args", func(t *testing.T) {
+		v := deployArgs{Source: "-a.txt", Targets: []string{"x"}}
+		args := CommandString(&v)
+		if got := strings.Join(args, " "); got != "-- -a.txt 0 x" {
+			t.Errorf("unexpected args: %s", got)
+		}
+		var actual deployArgs
+		fs := FlagSetStruct("deploy", flag.ContinueOnError, &actual)
+		if err := fs.Parse(args); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		if err := Check(fs); err != nil || !reflect.DeepEqual(actual, v) {
+			t.Errorf("expected %#v, got %#v (err: %v)", v, actual, err)
+		}
+	})
+
+	t.Run("forgets the flagsets of CommandString", func(t *testing.T) {
+		registered := func() int {
+			registeredArgs.Lock()
+			defer registeredArgs.Unlock()
+			return len(registeredArgs.sets)
+		}
+		before := registered()
+		v := deployArgs{Source: "a.txt"}
+		for i := 0; i < 10; i++ {
+			CommandString(&v)
+		}
+		var bad struct {
+			A string `flage-pos:"1"`
+		}
+		if _, err := FlagSetStructE("bad", flag.ContinueOnError, &bad); err == nil {
+			t.Errorf("expected error for a gap in indexes")
+		}
+		if after := registered(); after != before {
+			t.Errorf("expected %d flagsets with positional arguments, got %d", before, after)
+		}
+	})
+
+	t.Run("rejects invalid tags", func(t *testing.T) {
+		var gap struct {
+			A string `flage-pos:"1"`
+		}
+		if err := StructVarE(&gap, flag.NewFlagSet("", flag.ContinueOnError)); err == nil {
+			t.Errorf("expected error for a gap in indexes")
+		}
+		var order struct {
+			A string `flage-pos:"0,a;optional"`
+			B string `flage-pos:"1,b"`
+		}
+		if err := StructVarE(&order, flag.NewFlagSet("", flag.ContinueOnError)); err == nil {
+			t.Errorf("expected error for a required argument after an optional one")
+		}
+		var rest struct {
+			A string `flage-rest:""`
+		}
+		if err := StructVarE(&rest, flag.NewFlagSet("", flag.ContinueOnError)); err == nil {
+			t.Errorf("expected error for a flage-rest field that's not a slice")
+		}
+	})
+}
@@ -0,0 +1,276 @@
+package flage
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Source is where the value of a flag came from
+type Source int
+
+const (
+	DefaultValue Source = iota // the default value, from the flage tag or a Defaults method
+	CommandLine                // parsed from arguments, eg - by flag.FlagSet.Parse
+	EnvVar                     // an environment variable, see ApplyEnv
+	ConfigFile                 // a config file, see ApplyConfigFile
+)
+
+func (s Source) String() string {
+	switch s {
+	case DefaultValue:
+		return "default"
+	case CommandLine:
+		return "command line"
+	case EnvVar:
+		return "env var"
+	case ConfigFile:
+		return "config file"
+	default:
+		return fmt.Sprintf("Source(%d)", int(s))
+	}
+}
+
+// Origin describes where the value of a flag came from
+type Origin struct {
+	Source Source
+	Detail string // the flag name, env var or config file the value came from
+	Raw    string // the last string the value was parsed from
+}
+
+func (o Origin) String() string {
+	if o.Detail == "" {
+		return o.Source.String()
+	}
+	return o.Source.String() + " " + o.Detail
+}
+
+// Provenance returns where the current value of the flag with the given name
+// came from. Returns false if there's no such flag in fs.
+//
+// Values are tracked for flags registered by StructVar or by this package's
+// Var functions (eg - IntVar). Flags defined with the flag package itself are
+// reported as CommandLine if they were set by parsing, ConfigFile if they were
+// changed otherwise, or DefaultValue if they still have their default.
+//
+// If fs is nil, then flag.CommandLine is used instead.
+func Provenance(fs *flag.FlagSet, name string) (Origin, bool) {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	f := fs.Lookup(name)
+	if f == nil {
+		return Origin{}, false
+	}
+	sf := lookupStructFlag(fs, name)
+	if sf == nil {
+		return plainProvenance(fs, f), true
+	}
+	if !sf.set {
+		return Origin{Source: DefaultValue, Raw: fs.Lookup(sf.names[0]).DefValue}, true
+	}
+	origin := sf.origin
+	if origin.Source == CommandLine && origin.Detail == "" {
+		// report the name that was used, which may be an alias
+		fs.Visit(func(set *flag.Flag) {
+			if lookupStructFlag(fs, set.Name) == sf {
+				origin.Detail = "-" + set.Name
+			}
+		})
+	}
+	return origin, true
+}
+
+// plainProvenance returns where the value of f, which wasn't registered by
+// StructVar, came from
+func plainProvenance(fs *flag.FlagSet, f *flag.Flag) Origin {
+	visited := false
+	fs.Visit(func(set *flag.Flag) { visited = visited || set == f })
+	t, ok := f.Value.(originTracker)
+	if !ok {
+		switch {
+		case visited:
+			return Origin{Source: CommandLine, Detail: "-" + f.Name, Raw: f.Value.String()}
+		case f.Value.String() != f.DefValue:
+			// set without parsing, which only ApplyConfigFile does
+			return Origin{Source: ConfigFile, Raw: f.Value.String()}
+		}
+		return Origin{Source: DefaultValue, Raw: f.DefValue}
+	}
+	origin, set := t.lastOrigin()
+	if !set {
+		return Origin{Source: DefaultValue, Raw: f.DefValue}
+	}
+	if origin.Source == CommandLine && origin.Detail == "" && visited {
+		origin.Detail = "-" + f.Name
+	}
+	return origin
+}
+
+// PrintConfig writes the current value of every flag in fs to w in the format
+// read by ReadConfigFile, so it can be saved and loaded later. Each flag is
+// preceded by a comment describing its provenance (see Provenance):
+//
+//	# -port: env var APP_PORT
+//	-port=8080
+//
+// Flags without a value, like empty slices or nil pointers, are only commented.
+// Secret flags are commented out, with "****" instead of their values, so
+// loading the config leaves them unset. Flags of variants that
+// weren't selected are left out (see the variant option of StructVar).
+//
+// If fs is nil, then flag.CommandLine is used instead.
+func PrintConfig(w io.Writer, fs *flag.FlagSet) {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	seen := make(map[*structFlag]bool)
+	fs.VisitAll(func(f *flag.Flag) {
+		name := f.Name
+		values := []string{f.Value.String()}
+		line := "-%s=%s\n"
+		switch v := f.Value.(type) {
+		case *deprecatedFlag, *negatedFlag, *helpAllFlag:
+			return
+		case *structFlag:
+			if seen[v] || v.owner.inactiveVariant() != nil {
+				return
+			}
+			seen[v] = true
+			name = v.names[0]
+			values = v.mask(configValues(v))
+			if v.secret {
+				// loading the mask would set the flag to it
+				line = "# " + line
+			}
+		}
+		origin, _ := Provenance(fs, name)
+		fmt.Fprintf(w, "# -%s: %s\n", name, origin)
+		for _, value := range values {
+			fmt.Fprintf(w, line, name, shellQuote(value))
+		}
+	})
+}
+
+// configValues returns the values to set a struct flag to its current value
+func configValues(sf *structFlag) []string {
+	switch v := sf.Value.(type) {
+	case *sliceValue:
+		values := make([]string, v.ptr.Len())
+		for i := range values {
+			values[i] = v.codec.format(v.ptr.Index(i))
+		}
+		return values
+	case *mapValue:
+		return formatMap(v.ptr, v.key, v.elem)
+	case *ptrValue:
+		if v.ptr.IsNil() {
+			return nil
+		}
+	}
+	switch sf.field.Addr().Interface().(type) {
+	case *StringSlice, *FloatSlice, *Int64Slice, *Uint64Slice:
+		// each value that's set is appended
+		values := make([]string, sf.field.Len())
+		for i := range values {
+			values[i] = fmt.Sprint(sf.field.Index(i).Interface())
+		}
+		return values
+	}
+	return []string{sf.Value.String()}
+}
+
+// shellQuote quotes s, if needed, so that it's parsed as a single argument
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"\\#$`") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+// ApplyConfigFile sets the flags in fs from a config file (see
+// ReadConfigFile). Like ApplyEnv, it only sets flags that weren't already set,
+// so that the command line takes precedence.
+//
+// If fs is nil, then flag.CommandLine is used instead.
+func ApplyConfigFile(fs *flag.FlagSet, file string) error {
+	args, err := ReadConfigFile(file)
+	if err != nil {
+		return err
+	}
+	return applyConfig(fs, args, file)
+}
+
+// configValue records the values of a flag in a config file
+type configValue struct {
+	name   string
+	isBool bool
+	pairs  *[][2]string
+}
+
+func (c *configValue) String() string   { return "" }
+func (c *configValue) IsBoolFlag() bool { return c.isBool }
+func (c *configValue) Set(s string) error {
+	*c.pairs = append(*c.pairs, [2]string{c.name, s})
+	return nil
+}
+
+// applyConfig sets the flags in fs from args parsed from file
+func applyConfig(fs *flag.FlagSet, args []string, file string) error {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	// parse with a separate flagset, since flags that are already set are skipped
+	var pairs [][2]string
+	config := flag.NewFlagSet(file, flag.ContinueOnError)
+	config.SetOutput(io.Discard)
+	fs.VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		config.Var(&configValue{name: f.Name, isBool: ok && b.IsBoolFlag(), pairs: &pairs}, f.Name, "")
+	})
+	if err := config.Parse(args); err != nil {
+		return fmt.Errorf("config file %s: %w", file, err)
+	}
+	if config.NArg() > 0 {
+		return fmt.Errorf("config file %s: unexpected argument %q", file, config.Arg(0))
+	}
+
+	skip := make(map[any]bool)
+	visitStructFlags(fs, func(sf *structFlag) { skip[sf] = sf.set })
+	fs.Visit(func(f *flag.Flag) {
+		if lookupStructFlag(fs, f.Name) == nil {
+			skip[f] = true
+		}
+	})
+	var errs []error
+	for _, pair := range pairs {
+		name, value := pair[0], pair[1]
+		var err error
+		sf := lookupStructFlag(fs, name)
+		if sf != nil {
+			if skip[sf] {
+				continue
+			}
+			if _, ok := fs.Lookup(name).Value.(*negatedFlag); ok {
+				value, err = negate(value)
+			}
+			if err == nil {
+				err = sf.setFrom(value, Origin{Source: ConfigFile, Detail: file, Raw: value})
+			}
+		} else if f := fs.Lookup(name); skip[f] {
+			continue
+		} else if t, ok := f.Value.(originTracker); ok {
+			err = t.setFrom(value, Origin{Source: ConfigFile, Detail: file, Raw: value})
+		} else {
+			err = f.Value.Set(value)
+		}
+		if err != nil && sf != nil && sf.secret {
+			errs = append(errs, fmt.Errorf("invalid value %s for flag -%s in config file %s: %w", secretMask, name, file, sf.redact(err, pair[1])))
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("invalid value %q for flag -%s in config file %s: %w", pair[1], name, file, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,133 @@
+package flage
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProvenance(t *testing.T) {
+	type Example struct {
+		Port    int               `flage:"port,80" flage-env:"APP_PORT"`
+		Host    string            `flage:"host|h,localhost"`
+		Name    string            `flage:"name"`
+		Tags    []string          `flage:"tag"`
+		Labels  map[string]string `flage:"label"`
+		Timeout time.Duration     `flage:"timeout,5s"`
+		Note    *string           `flage:"note"`
+		Verbose bool              `flage:"verbose"`
+	}
+
+	file := filepath.Join(t.TempDir(), "app.conf")
+	config := "# app config\n-name 'my app' -host ignored.com\n-tag a -tag 'b c'\n-verbose\n"
+	if err := os.WriteFile(file, []byte(config), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	var example Example
+	fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+	var plain string
+	fs.StringVar(&plain, "plain", "x", "")
+	if err := fs.Parse([]string{"-h", "example.com", "-plain", "y", "-label", "k=v"}); err != nil {
+		t.Fatalf("failed to parse flags: %s", err.Error())
+	}
+	if err := ApplyEnv(fs, NewEnv(nil, EnvMap{"APP_PORT": {"8080"}})); err != nil {
+		t.Fatalf("failed to apply env: %v", err)
+	}
+	if err := ApplyConfigFile(fs, file); err != nil {
+		t.Fatalf("failed to apply config: %v", err)
+	}
+	if example.Host != "example.com" || example.Name != "my app" || strings.Join(example.Tags, "|") != "a|b c" {
+		t.Errorf("unexpected values: %#v", example)
+	}
+
+	for name, expected := range map[string]Origin{
+		"port":    {Source: EnvVar, Detail: "APP_PORT", Raw: "8080"},
+		"host":    {Source: CommandLine, Detail: "-h", Raw: "example.com"},
+		"h":       {Source: CommandLine, Detail: "-h", Raw: "example.com"},
+		"name":    {Source: ConfigFile, Detail: file, Raw: "my app"},
+		"tag":     {Source: ConfigFile, Detail: file, Raw: "b c"},
+		"timeout": {Source: DefaultValue, Raw: "5s"},
+		"plain":   {Source: CommandLine, Detail: "-plain", Raw: "y"},
+	} {
+		got, ok := Provenance(fs, name)
+		if !ok || got != expected {
+			t.Errorf("-%s: expected %#v, got %#v", name, expected, got)
+		}
+	}
+	if _, ok := Provenance(fs, "missing"); ok {
+		t.Errorf("expected no provenance for missing flag")
+	}
+
+	fs.VisitAll(func(f *flag.Flag) { Reset(f.Value) })
+	if got, _ := Provenance(fs, "port"); got.Source != DefaultValue {
+		t.Errorf("expected reset to clear provenance, got %#v", got)
+	}
+
+	t.Run("PrintConfig round trips", func(t *testing.T) {
+		var example Example
+		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+		args := []string{"-port", "8080", "-name", "it's #1", "-tag", "a", "-tag", "b c", "-label", "k=v", "-note", "", "-verbose"}
+		if err := fs.Parse(args); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		var out strings.Builder
+		PrintConfig(&out, fs)
+		if !strings.Contains(out.String(), "# -port: command line -port\n-port=8080\n") || !strings.Contains(out.String(), "# -timeout: default\n-timeout=5s\n") {
+			t.Errorf("unexpected config:\n%s", out.String())
+		}
+
+		parsed, err := ParseConfigFile(out.String())
+		if err != nil {
+			t.Fatalf("failed to parse printed config: %v\n%s", err, out.String())
+		}
+		var copied Example
+		if err := FlagSetStruct("test", flag.ContinueOnError, &copied).Parse(parsed); err != nil {
+			t.Fatalf("failed to parse printed config: %v\n%s", err, out.String())
+		}
+		if !reflect.DeepEqual(example, copied) {
+			t.Errorf("expected %#v, got %#v from config:\n%s", example, copied, out.String())
+		}
+	})
+	t.Run("tracks config values of plain flags", func(t *testing.T) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		var n, std int
+		var d time.Duration
+		IntVar(fs, &n, "n", 1, "")
+		DurationVar(fs, &d, "d", time.Second, "")
+		fs.IntVar(&std, "std", 1, "")
+		if err := fs.Parse([]string{"-d", "2s"}); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		file := filepath.Join(t.TempDir(), "plain.conf")
+		if err := os.WriteFile(file, []byte("-n 5 -d 3s -std 7\n"), 0644); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+		if err := ApplyConfigFile(fs, file); err != nil {
+			t.Fatalf("failed to apply config: %v", err)
+		}
+		for name, expected := range map[string]Origin{
+			"n":   {Source: ConfigFile, Detail: file, Raw: "5"},
+			"d":   {Source: CommandLine, Detail: "-d", Raw: "2s"},
+			"std": {Source: ConfigFile, Raw: "7"},
+		} {
+			if got, _ := Provenance(fs, name); got != expected {
+				t.Errorf("-%s: expected %#v, got %#v", name, expected, got)
+			}
+		}
+		var out strings.Builder
+		PrintConfig(&out, fs)
+		if !strings.Contains(out.String(), "# -n: config file "+file+"\n-n=5\n") {
+			t.Errorf("unexpected config:\n%s", out.String())
+		}
+
+		fs.VisitAll(func(f *flag.Flag) { Reset(f.Value) })
+		if got, _ := Provenance(fs, "n"); got != (Origin{Source: DefaultValue, Raw: "1"}) {
+			t.Errorf("expected reset to clear provenance, got %#v", got)
+		}
+	})
+}
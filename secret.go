@@ -0,0 +1,86 @@
+package flage
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// secretMask is shown instead of the value of secret flags
+const secretMask = "****"
+
+// Secret holds a value that shouldn't be shown, like a password or an API
+// token. Its String and GoString methods return a mask, so it's not leaked by
+// printing or logging it.
+//
+// StructVar registers Secret fields like fields of type T with the secret
+// option (see StructVar):
+//
+//	type Config struct {
+//	  Token flage.Secret[string] `flage:"token,,API token"`
+//	}
+//
+// Use Reveal to get the value.
+type Secret[T any] struct {
+	value T
+}
+
+// NewSecret returns a Secret holding v
+func NewSecret[T any](v T) Secret[T] { return Secret[T]{value: v} }
+
+// Reveal returns the value of the secret
+func (s Secret[T]) Reveal() T { return s.value }
+
+// String returns a mask instead of the value
+func (s Secret[T]) String() string { return secretMask }
+
+// GoString returns a mask instead of the value, for the %#v format
+func (s Secret[T]) GoString() string { return secretMask }
+
+// secretValue returns the value of the secret, for StructVar to register
+func (s *Secret[T]) secretValue() reflect.Value { return reflect.ValueOf(&s.value).Elem() }
+
+// secretHolder is implemented by pointers to Secret
+type secretHolder interface {
+	secretValue() reflect.Value
+}
+
+// redact replaces the given values in the message of err with a mask, where
+// they're quoted, like the %q of parse and validation errors. Unquoted values
+// are left alone, since a short value like "1" would mangle the rest of the
+// message, eg - "must be at least 1000".
+func redact(err error, values ...string) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		msg = strings.ReplaceAll(msg, strconv.Quote(v), strconv.Quote(secretMask))
+	}
+	return errors.New(msg)
+}
+
+// mask returns the values with every value masked, if sf is secret
+func (sf *structFlag) mask(values []string) []string {
+	if !sf.secret {
+		return values
+	}
+	masked := make([]string, len(values))
+	for i := range masked {
+		masked[i] = secretMask
+	}
+	return masked
+}
+
+// redact replaces the current and given values of sf in the message of err, if
+// sf is secret
+func (sf *structFlag) redact(err error, values ...string) error {
+	if !sf.secret || err == nil {
+		return err
+	}
+	return redact(err, append(values, configValues(sf)...)...)
+}
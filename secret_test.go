@@ -0,0 +1,188 @@
+package flage
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecretFlags(t *testing.T) {
+	type Example struct {
+		Token    string         `flage:"token;secret,s3cr3t-default,API token" flage-env:"APP_TOKEN"`
+		PIN      int            `flage:"pin;secret" flage-validate:"min=1000"`
+		Password Secret[string] `flage:"password,hunter2,database password"`
+		Name     string         `flage:"name,app"`
+		Code     string         `flage:"code;secret,red" flage-validate:"oneof=red|blue"`
+	}
+	newFlagSet := func(out *bytes.Buffer) (*Example, *flag.FlagSet) {
+		var e Example
+		fs := FlagSetStruct("test", flag.ContinueOnError, &e)
+		fs.SetOutput(out)
+		return &e, fs
+	}
+
+	t.Run("sets values", func(t *testing.T) {
+		var out bytes.Buffer
+		e, fs := newFlagSet(&out)
+		if e.Token != "s3cr3t-default" || e.Password.Reveal() != "hunter2" {
+			t.Errorf("expected default values, got %#v", e)
+		}
+		if err := fs.Parse([]string{"-token", "abc", "-password", "letmein", "-pin", "1234"}); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		if err := Check(fs); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if e.Token != "abc" || e.Password.Reveal() != "letmein" || e.PIN != 1234 {
+			t.Errorf("unexpected values: %#v", e)
+		}
+		if s := fmt.Sprintf("%v %#v", e.Password, e.Password); strings.Contains(s, "letmein") {
+			t.Errorf("expected Secret to be masked when printed, got %s", s)
+		}
+		if s := fs.Lookup("token").Value.String(); s != "****" {
+			t.Errorf("expected masked String, got %q", s)
+		}
+	})
+
+	t.Run("leaves defaults out of help", func(t *testing.T) {
+		var out bytes.Buffer
+		_, fs := newFlagSet(&out)
+		fs.Usage()
+		if strings.Contains(out.String(), "s3cr3t") || strings.Contains(out.String(), "hunter2") || strings.Contains(out.String(), "****") {
+			t.Errorf("expected no secret defaults in help, got:\n%s", out.String())
+		}
+		if !strings.Contains(out.String(), `(default "app")`) {
+			t.Errorf("expected other defaults in help, got:\n%s", out.String())
+		}
+	})
+
+	t.Run("masks dumps", func(t *testing.T) {
+		var out bytes.Buffer
+		e, fs := newFlagSet(&out)
+		if err := fs.Parse([]string{"-token", "abc", "-password=letmein"}); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		var config bytes.Buffer
+		PrintConfig(&config, fs)
+		origin, _ := Provenance(fs, "token")
+		dump := strings.Join(append(CommandString(e), config.String(), origin.Raw), "\n")
+		for _, s := range []string{"abc", "letmein", "s3cr3t"} {
+			if strings.Contains(dump, s) {
+				t.Errorf("expected %q to be masked, got:\n%s", s, dump)
+			}
+		}
+		if !strings.Contains(config.String(), "# -token=****\n") || len(CommandString(e)) != 0 {
+			t.Errorf("expected masked values, got:\n%s", dump)
+		}
+	})
+
+	t.Run("leaves secrets out of command line args", func(t *testing.T) {
+		v := Example{Token: "abc", PIN: 1234, Password: NewSecret("letmein"), Name: "svc", Code: "blue"}
+		args := CommandString(&v)
+		if got := strings.Join(args, " "); got != "-name svc" {
+			t.Errorf("unexpected args: %s", got)
+		}
+		var out bytes.Buffer
+		parsed, fs := newFlagSet(&out)
+		if err := fs.Parse(args); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		if parsed.Name != "svc" || parsed.Token != "s3cr3t-default" || parsed.Password.Reveal() != "hunter2" || parsed.PIN != 0 {
+			t.Errorf("expected secrets to keep their defaults, got %#v", parsed)
+		}
+
+		var pos struct {
+			Token string `flage-pos:"0,token;secret"`
+			Host  string `flage-pos:"1,host"`
+		}
+		pos.Token, pos.Host = "abc", "example.com"
+		if got := strings.Join(CommandString(&pos), " "); got != "**** example.com" {
+			t.Errorf("expected secret arguments to be masked in place, got %s", got)
+		}
+	})
+
+	t.Run("leaves secrets out of loaded configs", func(t *testing.T) {
+		var out bytes.Buffer
+		e, fs := newFlagSet(&out)
+		if err := fs.Parse([]string{"-token", "abc", "-password=letmein", "-pin", "1234", "-name", "svc"}); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		var config bytes.Buffer
+		PrintConfig(&config, fs)
+		file := filepath.Join(t.TempDir(), "app.conf")
+		if err := os.WriteFile(file, config.Bytes(), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, loadedFlags := newFlagSet(&out)
+		if err := ApplyConfigFile(loadedFlags, file); err != nil {
+			t.Fatalf("failed to load printed config: %v\n%s", err, config.String())
+		}
+		if loaded.Name != e.Name || loaded.Token != "s3cr3t-default" || loaded.Password.Reveal() != "hunter2" || loaded.PIN != 0 {
+			t.Errorf("expected secrets to keep their defaults, got %#v from config:\n%s", loaded, config.String())
+		}
+	})
+
+	t.Run("redacts errors", func(t *testing.T) {
+		var out bytes.Buffer
+		e, fs := newFlagSet(&out)
+		if err := fs.Parse([]string{"-pin", "12x4"}); err != nil {
+			t.Fatalf("expected parse errors of secrets to be returned by Check, got %v", err)
+		}
+		if e.PIN != 0 {
+			t.Errorf("expected -pin to be unchanged, got %d", e.PIN)
+		}
+		errs := []error{Check(fs)}
+		if !strings.Contains(errs[0].Error(), "invalid value **** for flag -pin") {
+			t.Errorf("expected an invalid value error, got %v", errs[0])
+		}
+
+		fs.VisitAll(func(f *flag.Flag) { Reset(f.Value) })
+		if err := fs.Parse([]string{"-pin", "999", "-code", "999"}); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		errs = append(errs, Check(fs))
+
+		fs.VisitAll(func(f *flag.Flag) { Reset(f.Value) })
+		var pin struct {
+			PIN int `flage:"pin;secret" flage-env:"APP_PIN"`
+		}
+		envFlags := FlagSetStruct("test", flag.ContinueOnError, &pin)
+		errs = append(errs, ApplyEnv(envFlags, NewEnv(nil, EnvMap{"APP_PIN": {"12x4"}})))
+		errs = append(errs, applyConfig(envFlags, []string{"-pin", "12x4"}, "app.conf"))
+
+		var bad struct {
+			PIN int `flage:"pin;secret,12x4"`
+		}
+		errs = append(errs, StructVarE(&bad, flag.NewFlagSet("", flag.ContinueOnError)))
+
+		for _, err := range errs {
+			if err == nil {
+				t.Errorf("expected an error")
+			} else if strings.Contains(err.Error(), "12x4") || strings.Contains(err.Error(), "999") {
+				t.Errorf("expected error to be redacted, got %v", err)
+			}
+		}
+		if strings.Contains(out.String(), "12x4") {
+			t.Errorf("expected no secrets in output, got:\n%s", out.String())
+		}
+		var fe *FieldError
+		if !errors.As(errs[len(errs)-1], &fe) {
+			t.Errorf("expected a *FieldError, got %v", errs[len(errs)-1])
+		}
+
+		// short values only appear in the message by coincidence
+		fs.VisitAll(func(f *flag.Flag) { Reset(f.Value) })
+		if err := fs.Parse([]string{"-pin", "1"}); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		if err := Check(fs); err == nil || !strings.Contains(err.Error(), "must be at least 1000") {
+			t.Errorf("expected the message to be intact, got %v", err)
+		}
+	})
+}
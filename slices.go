@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
+	"reflect"
 	"strconv"
 	"strings"
 )
@@ -141,3 +142,67 @@ func (i *StringSlice) Set(value string) error {
 
 // Reset creates a new slice to use
 func (i *StringSlice) Reset() { *i = make(StringSlice, 0) }
+
+// sliceValue is used by StructVar for plain slice fields. Like the other slice
+// types, each use of the flag appends to the slice. The first use of the flag
+// replaces any default values.
+type sliceValue struct {
+	ptr      reflect.Value // addressable slice
+	codec    codec
+	defvalue []reflect.Value
+	changed  bool // set since the last Reset
+}
+
+func newSliceValue(ptr reflect.Value, c codec, defvalue string) (*sliceValue, error) {
+	s := &sliceValue{ptr: ptr, codec: c}
+	if defvalue != "" {
+		for _, part := range strings.Split(defvalue, ",") {
+			v, err := c.parse(strings.TrimSpace(part))
+			if err != nil {
+				return nil, err
+			}
+			s.defvalue = append(s.defvalue, v)
+		}
+	}
+	s.Reset()
+	return s, nil
+}
+
+func (s *sliceValue) IsBoolFlag() bool { return s.codec.isBool }
+
+// String returns a string with ", " joined between each element
+func (s *sliceValue) String() string {
+	if s == nil || !s.ptr.IsValid() {
+		return ""
+	}
+	var b strings.Builder
+	for i, n := 0, s.ptr.Len(); i < n; i++ {
+		if i != 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(s.codec.format(s.ptr.Index(i)))
+	}
+	return b.String()
+}
+
+// Set appends to the slice, replacing the default values on first use.
+func (s *sliceValue) Set(value string) error {
+	v, err := s.codec.parse(value)
+	if err != nil {
+		return err
+	}
+	if !s.changed {
+		s.ptr.Set(reflect.MakeSlice(s.ptr.Type(), 0, 1))
+		s.changed = true
+	}
+	s.ptr.Set(reflect.Append(s.ptr, v))
+	return nil
+}
+
+func (s *sliceValue) Get() any { return s.ptr.Interface() }
+
+// Reset creates a new slice containing the default values
+func (s *sliceValue) Reset() {
+	s.ptr.Set(reflect.Append(reflect.MakeSlice(s.ptr.Type(), 0, len(s.defvalue)), s.defvalue...))
+	s.changed = false
+}
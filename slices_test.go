@@ -4,6 +4,7 @@ import (
 	"flag"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestInt64Slice(t *testing.T) {
@@ -134,3 +135,59 @@ func TestStringSlice(t *testing.T) {
 		})
 	}
 }
+
+func TestStructVarSliceFields(t *testing.T) {
+	type Example struct {
+		Strs  []string
+		Ints  []int           `flage:"int,'1,2'"`
+		Durs  []time.Duration `flage:"dur"`
+		Texts []TypeWithTextMarshals
+	}
+
+	var example Example
+	fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+	if !reflect.DeepEqual(example.Ints, []int{1, 2}) {
+		t.Errorf("expected default values, got %#v", example.Ints)
+	}
+
+	err := fs.Parse([]string{
+		"-strs", "a", "-strs", "b",
+		"-int", "3",
+		"-dur", "1s", "-dur", "2m",
+		"-texts", "4",
+	})
+	if err != nil {
+		t.Fatalf("failed to parse flags: %s", err.Error())
+	}
+	expected := Example{
+		Strs:  []string{"a", "b"},
+		Ints:  []int{3},
+		Durs:  []time.Duration{time.Second, 2 * time.Minute},
+		Texts: []TypeWithTextMarshals{{X: 4}},
+	}
+	if !reflect.DeepEqual(expected, example) {
+		t.Errorf("expected %#v, got %#v", expected, example)
+	}
+	if s := fs.Lookup("dur").Value.String(); s != "1s, 2m0s" {
+		t.Errorf("expected String() to join elements, got %q", s)
+	}
+
+	ints := example.Ints
+	fs.VisitAll(func(f *flag.Flag) { Reset(f.Value) })
+	expected = Example{
+		Strs:  []string{},
+		Ints:  []int{1, 2},
+		Durs:  []time.Duration{},
+		Texts: []TypeWithTextMarshals{},
+	}
+	if !reflect.DeepEqual(expected, example) {
+		t.Errorf("expected %#v, got %#v", expected, example)
+	}
+	if !reflect.DeepEqual(ints, []int{3}) {
+		t.Errorf("expected Reset() to not mutate the previous slice, got %#v", ints)
+	}
+
+	if err := fs.Parse([]string{"-int", "abc"}); err == nil {
+		t.Error("expected error for invalid element")
+	}
+}
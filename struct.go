@@ -2,21 +2,458 @@ package flage
 
 import (
 	"encoding"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"reflect"
 	"strconv"
 	"strings"
-	"time"
 )
 
-// FlagSetStruct makes a new flagset based on an output string to set to
+// FlagSetStruct makes a new flagset based on an output string to set to.
+// The flagset's Usage uses PrintDefaults.
+//
+// Call Check after parsing: it reports required flags, validation errors and
+// invalid values of secret flags, which Parse doesn't. See StructVar.
 func FlagSetStruct(name string, errHandling flag.ErrorHandling, out any) *flag.FlagSet {
-	fs := flag.NewFlagSet(name, errHandling)
-	StructVar(out, fs)
+	fs, err := FlagSetStructE(name, errHandling, out)
+	if err != nil {
+		panic(err)
+	}
 	return fs
 }
 
+// FlagSetStructE performs like FlagSetStruct, but returns an error instead of panicking. See StructVarE.
+func FlagSetStructE(name string, errHandling flag.ErrorHandling, out any) (*flag.FlagSet, error) {
+	return flagSetStruct(name, errHandling, out, StructOptions{})
+}
+
+func flagSetStruct(name string, errHandling flag.ErrorHandling, out any, opts StructOptions) (*flag.FlagSet, error) {
+	fs := flag.NewFlagSet(name, errHandling)
+	fs.Usage = func() { defaultUsage(fs) }
+	if err := StructVarWithOptions(out, fs, opts); err != nil {
+		forgetArgs(fs)
+		return nil, err
+	}
+	return fs, nil
+}
+
+var (
+	// ErrUnsupportedType is returned for struct fields whose type cannot be used as a flag
+	ErrUnsupportedType = errors.New("unsupported type")
+	// ErrDuplicateFlag is returned when a struct field's flag name is already defined
+	ErrDuplicateFlag = errors.New("duplicate flag name")
+)
+
+// FieldError describes a struct field that couldn't be registered as a flag.
+type FieldError struct {
+	Struct string            // name of the struct type
+	Field  string            // name of the struct field
+	Tag    reflect.StructTag // tags of the struct field
+	Err    error
+}
+
+func (e *FieldError) Error() string {
+	if e.Tag == "" {
+		return fmt.Sprintf("%s.%s %s", e.Struct, e.Field, e.Err)
+	}
+	return fmt.Sprintf("%s.%s %s (tag: `%s`)", e.Struct, e.Field, e.Err, e.Tag)
+}
+
+func (e *FieldError) Unwrap() error { return e.Err }
+
+// StructOptions configures how StructVarWithOptions registers a struct's fields.
+type StructOptions struct {
+	// Separator is placed between the name of a nested struct field and the
+	// names of its fields. Defaults to ".", eg - "-db.host".
+	Separator string
+
+	// EnvPrefix, when non-empty, binds every field without a flage-env tag to
+	// the environment variable named EnvPrefix followed by the upper-cased flag
+	// name (with non-alphanumeric characters replaced by underscores).
+	EnvPrefix string
+
+	// Types is used to parse and format field types. Defaults to DefaultTypes.
+	Types *TypeRegistry
+
+	// Warnings receives warnings about using deprecated flag names. Defaults
+	// to the output of the FlagSet.
+	Warnings io.Writer
+
+	// Naming converts field names into flag names for fields without a name in
+	// their tag, which also changes the names of their environment variables
+	// (see EnvPrefix). Defaults to LowerCase, eg - "MaxRetries" is
+	// "-maxretries", while KebabCase makes it "-max-retries".
+	Naming NamingStrategy
+}
+
+// structFlag wraps every flag.Value registered by StructVar to record
+// metadata about the field it came from and whether it was explicitly set.
+type structFlag struct {
+	flag.Value
+	names     []string      // flag names, starting with the primary name followed by aliases
+	field     reflect.Value // the struct field the value is stored in
+	fieldName string        // eg - "Config.Port"
+	zero      string        // String() of the zero value of the field
+	owner     *structInfo   // the struct containing the field
+	preset    reflect.Value // optional, the default value set by a Defaulter
+	order     int           // the order StructVar registered the field in
+
+	env        string // environment variable to fall back to, see ApplyEnv
+	required   bool   // see Check
+	validators []validator
+	visibility Visibility // see PrintDefaults
+	metavar    string     // optional, the placeholder for the value in help
+	group      *flagGroup // optional, see PrintDefaults
+
+	secret      bool          // see the secret option of StructVar
+	err         error         // a parse error of a secret flag, see Set
+	constraints []*constraint // see AddConstraints
+	negation    string        // optional, the flag that sets the opposite bool, eg - "no-color"
+	choices     []string      // optional, the variants this flag selects between
+	set         bool
+	origin      Origin // see Provenance
+}
+
+func (f *structFlag) String() string {
+	if f == nil || f.Value == nil {
+		return ""
+	}
+	if f.secret {
+		return secretMask
+	}
+	return f.Value.String()
+}
+
+func (f *structFlag) Set(s string) error {
+	err := f.setFrom(s, Origin{Source: CommandLine, Raw: s})
+	if err != nil && f.secret {
+		// the flag package would print the value it failed to parse, so
+		// Check returns the error instead
+		f.err = fmt.Errorf("invalid value %s for flag -%s: %w", secretMask, f.names[0], f.redact(err, s))
+		return nil
+	}
+	return err
+}
+
+// setFrom sets the value of the flag, recording where it came from
+func (f *structFlag) setFrom(s string, origin Origin) error {
+	if err := f.Value.Set(s); err != nil {
+		return err
+	}
+	if f.secret {
+		origin.Raw = secretMask
+	}
+	f.set = true
+	f.origin = origin
+	return nil
+}
+
+func (f *structFlag) Get() any {
+	if g, ok := f.Value.(flag.Getter); ok {
+		return g.Get()
+	}
+	return nil
+}
+
+func (f *structFlag) IsBoolFlag() bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func (f *structFlag) Reset() {
+	Reset(f.Value)
+	if f.preset.IsValid() {
+		f.field.Set(cloneValue(f.preset))
+	}
+	f.set = false
+	f.origin = Origin{}
+	f.err = nil
+}
+
+// ErrMissingRequiredFlag is returned by Check for each required flag that was not set.
+var ErrMissingRequiredFlag = errors.New("missing required flag")
+
+// Check verifies the flags registered by StructVar after parsing. Call it after
+// every other source of values (eg - ApplyEnv) has been applied.
+//
+// Every required flag that was not set, either on the command line or via
+// ApplyEnv, is reported as an ErrMissingRequiredFlag. Every flag whose value
+// (including its default) violates its flage-validate tag is reported as a
+// *ValidationError. Invalid command line values of secret flags, which Parse
+// doesn't return so that the value isn't printed, are returned too. All
+// problems are returned together using errors.Join.
+//
+// Positional arguments of fields with flage-pos or flage-rest tags are set from
+// fs.Args(), unless a CommandIterator already set them. Missing or extra ones
+// are reported as ErrMissingArgument or ErrUnexpectedArgument.
+//
+// Constraints between flags, from flage tags or AddConstraints, are reported as
+// ErrMissingRequiredFlag or ErrConflictingFlags.
+//
+// Flags of variants that weren't selected (see the variant option of StructVar)
+// aren't checked, but are reported as ErrInactiveVariant if they were set.
+//
+// Before checking, Check calls Normalize on registered structs that implement
+// Normalizer. Afterwards, it calls Validate on the ones that implement Validator.
+//
+// The flage-validate tag contains comma separated constraints:
+//
+//   - min=N, max=N: inclusive bounds for numeric fields (including durations)
+//   - oneof=a|b|c: the value must be one of the given values
+//   - nonempty: strings, slices and maps must not be empty, pointers must not be nil
+//   - regex=EXPR: the value must match the regular expression. Must be the last
+//     constraint, since the expression may contain commas
+//   - len<=N, len>=N, len<N, len>N, len=N: length of strings, slices or maps
+//   - file-exists: the value is a path to an existing file
+//
+// Except for nonempty and len, constraints on slices and maps apply to each of
+// their values, and constraints on pointers apply to the value pointed to.
+//
+// If -help-all was given, Check calls fs.Usage and returns flag.ErrHelp instead,
+// like flag.FlagSet.Parse does for -help.
+//
+// If fs is nil, then flag.CommandLine is used instead.
+func Check(fs *flag.FlagSet) error {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	if helpAllRequested(fs) {
+		if fs.Usage != nil {
+			fs.Usage()
+		}
+		return flag.ErrHelp
+	}
+	errs := checkArgs(fs)
+	normalizeStructs(fs)
+	visitStructFlags(fs, func(sf *structFlag) {
+		if sf.err != nil {
+			errs = append(errs, sf.err)
+			return
+		}
+		if v := sf.owner.inactiveVariant(); v != nil {
+			if sf.set {
+				errs = append(errs, fmt.Errorf("%w: -%s requires %s", ErrInactiveVariant, sf.names[0], v))
+			}
+			return
+		}
+		if sf.required && !sf.set {
+			errs = append(errs, fmt.Errorf("%w: -%s", ErrMissingRequiredFlag, sf.names[0]))
+			return
+		}
+		for _, validate := range sf.validators {
+			if err := validate(sf.field); err != nil {
+				errs = append(errs, &ValidationError{Flag: sf.names[0], Field: sf.fieldName, Err: sf.redact(err)})
+			}
+		}
+	})
+	errs = append(errs, checkConstraints(fs)...)
+	errs = append(errs, validateStructs(fs)...)
+	return errors.Join(errs...)
+}
+
+// zeroString returns what sf.String() would be if the field was its zero value.
+// Like flag.PrintDefaults, it's "" if String panics for the zero value.
+func zeroString(sf *structFlag) (s string) {
+	current := reflect.New(sf.field.Type()).Elem()
+	current.Set(sf.field)
+	defer sf.field.Set(current)
+	defer func() {
+		if recover() != nil {
+			s = ""
+		}
+	}()
+	sf.field.Set(reflect.Zero(sf.field.Type()))
+	return sf.String()
+}
+
+// flagGroup is a section of flags in help
+type flagGroup struct {
+	title string
+	order int // groups are printed in the order they're declared
+}
+
+// lookupGroup returns the group of struct flags in fs with the given title,
+// or creates a new one after the existing groups.
+func lookupGroup(fs *flag.FlagSet, title string) *flagGroup {
+	var found *flagGroup
+	groups := make(map[*flagGroup]bool)
+	visitStructFlags(fs, func(sf *structFlag) {
+		if sf.group != nil {
+			groups[sf.group] = true
+			if sf.group.title == title {
+				found = sf.group
+			}
+		}
+	})
+	if found != nil {
+		return found
+	}
+	return &flagGroup{title: title, order: len(groups)}
+}
+
+// deprecatedFlag is registered for each deprecated name of a struct field. It
+// sets the same value as the field's other names, but warns when it's used.
+type deprecatedFlag struct {
+	*structFlag
+	name   string
+	fs     *flag.FlagSet
+	output io.Writer // optional, defaults to fs.Output()
+}
+
+func (d *deprecatedFlag) Set(s string) error {
+	out := d.output
+	if out == nil {
+		out = d.fs.Output()
+	}
+	fmt.Fprintf(out, "warning: -%s is deprecated, use -%s instead\n", d.name, d.names[0])
+	return d.structFlag.Set(s)
+}
+
+// negatedFlag is registered as "no-NAME" for bool struct flags. It sets the
+// opposite of the value it's given.
+type negatedFlag struct {
+	*structFlag
+}
+
+func (n *negatedFlag) Set(s string) error {
+	v, err := negate(s)
+	if err != nil {
+		return err
+	}
+	return n.structFlag.Set(v)
+}
+
+// negate returns the opposite of the bool in s
+func negate(s string) (string, error) {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return "", errParse
+	}
+	return strconv.FormatBool(!b), nil
+}
+
+// defineNegations registers the "no-" flags of bool struct flags in fs, unless
+// another flag already has that name.
+func defineNegations(fs *flag.FlagSet) {
+	var negatable []*structFlag
+	visitStructFlags(fs, func(sf *structFlag) {
+		if sf.negation != "" {
+			negatable = append(negatable, sf)
+		}
+	})
+	for _, sf := range negatable {
+		f := fs.Lookup(sf.negation)
+		if f == nil {
+			fs.Var(&negatedFlag{sf}, sf.negation, fs.Lookup(sf.names[0]).Usage)
+		} else if n, ok := f.Value.(*negatedFlag); !ok || n.structFlag != sf {
+			sf.negation = "" // the name is used by another flag
+		}
+	}
+}
+
+// visitStructFlags calls fn once for each value registered by StructVar in fs,
+// even if it's registered under multiple names.
+func visitStructFlags(fs *flag.FlagSet, fn func(sf *structFlag)) {
+	seen := make(map[*structFlag]bool)
+	fs.VisitAll(func(f *flag.Flag) {
+		if sf, ok := f.Value.(*structFlag); ok && !seen[sf] {
+			seen[sf] = true
+			fn(sf)
+		}
+	})
+}
+
+// fieldTag is the parsed form of a "flage" struct tag.
+type fieldTag struct {
+	name      string
+	def       string
+	docstring string
+	aliases   []string // additional flag names, eg - "verbose|v"
+	isSplat   bool
+	hasName   bool              // if the name was given in the tag
+	opts      map[string]string // options following the flag name, eg - "name;required"
+}
+
+func (t fieldTag) has(opt string) bool {
+	_, ok := t.opts[opt]
+	return ok
+}
+
+func parseFieldTag(f reflect.StructField, naming NamingStrategy) fieldTag {
+	tag := fieldTag{name: naming(f.Name)}
+	raw := strings.TrimSpace(f.Tag.Get("flage"))
+	if raw == "" {
+		return tag
+	}
+	parts := splitFieldTag(raw)
+	if len(parts) > 0 {
+		name, opts, _ := strings.Cut(parts[0], ";")
+		if name == "*" {
+			tag.isSplat = true
+		} else if name != "" {
+			names := strings.Split(name, "|")
+			tag.name, tag.aliases = names[0], names[1:]
+			tag.hasName = true
+		}
+		if opts != "" {
+			tag.opts = make(map[string]string)
+			for _, opt := range strings.Split(opts, ";") {
+				k, v, _ := strings.Cut(opt, "=")
+				tag.opts[strings.TrimSpace(k)] = strings.TrimSpace(v)
+			}
+		}
+	}
+	if len(parts) > 1 {
+		val := strings.TrimSpace(parts[1])
+		if val != "" {
+			switch f.Type.Kind() {
+			case reflect.String:
+				tag.def = parts[1]
+			default:
+				tag.def = val
+			}
+		}
+	}
+	if len(parts) > 2 {
+		tag.docstring = parts[2]
+	}
+	return tag
+}
+
+// splitFieldTag splits a tag into at most 3 comma separated parts. The default
+// value can be wrapped in single quotes to include commas, eg - "tags,'a,b',doc".
+func splitFieldTag(raw string) []string {
+	name, rest, found := strings.Cut(raw, ",")
+	if !found {
+		return []string{name}
+	}
+	if quoted := strings.TrimLeft(rest, " "); strings.HasPrefix(quoted, "'") {
+		if end := strings.Index(quoted[1:], "'"); end >= 0 {
+			def, doc := quoted[1:end+1], quoted[end+2:]
+			if doc, found := strings.CutPrefix(doc, ","); found {
+				return []string{name, def, doc}
+			}
+			return []string{name, def}
+		}
+	}
+	return append([]string{name}, strings.SplitN(rest, ",", 2)...)
+}
+
+func envName(prefix, flagName string) string {
+	return prefix + strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z':
+			return r - 'a' + 'A'
+		case 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, flagName)
+}
+
 func insertType(typeName string, docstring string) string {
 	return strings.ReplaceAll(docstring, "$type", "`"+typeName+"`")
 }
@@ -24,12 +461,82 @@ func insertType(typeName string, docstring string) string {
 // StructVar performs like flag.Var(...) but using a struct. Can optionally be annotated using tags.
 // If fs is nil, then the global functions in the flag package are used instead.
 //
+// StructVar doesn't change the Usage of fs, which prints every flag with
+// flag.FlagSet.PrintDefaults, including hidden ones. Use Usage (or
+// FlagSetStruct, which does) to print help that knows about the options below.
+//
 // Tags use the "flage" key with the following values: "<flagName>,<defaultValue>,<description>"
-// If <flagName> is empty, then the lowercase of the fieldname is used. Can be set to "-" to ignore.
+// If <flagName> is empty, then the lowercase of the fieldname is used (see
+// StructOptions.Naming). Can be set to "-" to ignore.
+// Additional names can be given separated by "|", eg - "verbose|v". All names set the same value.
 // Can be set to "*" to recursively parse the struct as top-level flags.
+// Other struct fields are parsed recursively with their flag name and a "." as
+// a prefix, eg - "-db.host". Embedded structs are parsed as top-level flags
+// unless they have a <flagName>.
 // If <defaultValue> is empty, then the zero value is used.
 // If <description> is empty, then the empty string is used.
 //
+// <flagName> can be followed by semicolon separated options, eg - "port;required".
+// Supported options are:
+//
+//   - required: Check returns an error if the flag was not set
+//   - deprecated=NAME|NAME: old names of the flag. Using them sets the flag but
+//     prints a warning (see StructOptions.Warnings). They're hidden from help.
+//   - hidden: the flag is accepted, but never shown in help
+//   - advanced: the flag is only shown in help when -help-all is given. StructVar
+//     defines -help-all if the struct has advanced flags.
+//   - group=TITLE: prints the flag under its own section in help. On a nested
+//     struct, it applies to all of its fields. Structs tagged with "*" are
+//     grouped by their field name unless they have a group option.
+//   - exactlyone=GROUP: exactly one of the flags with the same GROUP must be set
+//   - atmostone=GROUP: at most one of the flags with the same GROUP can be set
+//   - requires=NAME|NAME: the named flags must be set if this flag is set. Names
+//     are relative to the struct the field is in.
+//   - metavar=NAME: the placeholder for the value in help, eg - "-config FILE".
+//     By default it's derived from the type (eg - "INT", "DURATION",
+//     "KEY=VALUE" for maps) or the flage-validate tag ("{a|b|c}" for oneof,
+//     "PATH" for file-exists), unless the description has a name in backticks
+//     (see flag.UnquoteUsage).
+//   - secret: the value is never shown. Help leaves out its default, and
+//     String, CommandString, PrintConfig and Provenance show "****" instead.
+//     Errors that quote the value are redacted. Errors parsing it from
+//     the command line are returned by Check instead of flag.FlagSet.Parse,
+//     since the flag package would print the value, so call Check after
+//     parsing. Fields of type Secret[T] are always secret.
+//   - counter: for integer fields, each use of the flag without a value
+//     increments it, eg - "-v -v -v" is 3. See Counter.
+//   - variant=NAME: on a nested struct, its flags are only used when the
+//     string flag NAME (relative to the struct the field is in) is set to the
+//     struct's flag name, eg - "s3;variant=storage" for "-storage=s3". NAME
+//     only accepts the names of its variants, or "" to select none. Check
+//     skips the flags of other variants, but reports them if they were set
+//     (see ErrInactiveVariant). Help prints each variant as a group, eg -
+//     "-storage=s3", unless it has a group option.
+//
+// Bool fields (including *bool and OptionalBool) also get a "no-" flag that sets
+// the opposite value, eg - "-no-color" for "-color". It's named after the first
+// name of the flag, unless it's a single letter or another flag has that name.
+// Help shows both as "-[no-]color".
+//
+// The "flage-validate" tag lists constraints that Check verifies after parsing,
+// eg - `flage-validate:"min=1,max=65535"`. See Check for details.
+//
+// The "flage-pos" tag binds a field to a positional argument, from the
+// arguments left after parsing flags (see FlagSet.Args), instead of a flag. Its
+// value is "<index>,<name>,<description>", eg - `flage-pos:"0,source,file to
+// read"`. Indexes start at 0 and can't have gaps. Positional arguments are
+// required unless <name> has the "optional" option, eg - "1,dest;optional".
+// The "flage-rest" tag binds a slice field to the remaining arguments, with the
+// value "<name>,<description>". They're optional unless <name> has the
+// "required" option. Check sets positional arguments and returns
+// ErrMissingArgument or ErrUnexpectedArgument if there are too few or too many.
+// Help shows them in the usage synopsis, eg - "deploy [flags] <source> [targets...]".
+//
+// The "flage-env" tag names an environment variable that ApplyEnv uses when the
+// flag was not set on the command line. Use StructVarWithOptions to derive
+// variable names for every field from a common prefix instead. Set it to "-" to
+// opt a field out of that prefix.
+//
 // As per flag package, the following types are supported:
 //
 //   - string
@@ -43,6 +550,23 @@ func insertType(typeName string, docstring string) string {
 // Also additional types are supported:
 //
 //   - float32
+//   - int8 / int16 / int32, uint8 / uint16 / uint32 / uintptr
+//   - named types of any of the numeric types, string or bool (eg - "type Port uint16")
+//
+// Integers are parsed using the base given in the "flage-base" tag (default is 0,
+// see strconv.ParseInt) and values that don't fit in the field are rejected.
+//
+// Also composite types are supported:
+//
+//   - slices of any of the above types, where each use of the flag appends an
+//     element. Defaults are comma separated and can be wrapped in single quotes
+//     to include commas in the tag, eg - "hosts,'a,b',doc".
+//   - maps with keys and values of any of the above types, where each use of the
+//     flag adds a KEY=VALUE pair. Defaults are comma separated KEY=VALUE pairs,
+//     eg - "labels,'team=infra,env=prod',doc".
+//   - pointers to any of the above types, which stay nil unless the flag is set
+//     or has a default value.
+//   - any type registered with RegisterType, or StructOptions.Types
 //
 // Future support for built-in types may be added in the future.
 //
@@ -57,168 +581,369 @@ func insertType(typeName string, docstring string) string {
 //	StructVar(&f, nil)
 //	flag.Parse()
 func StructVar(v any, fs *flag.FlagSet) {
+	if err := StructVarE(v, fs); err != nil {
+		panic(err)
+	}
+}
+
+// StructVarE performs like StructVar, but returns an error instead of panicking
+// when the struct cannot be registered. Errors about specific fields are
+// returned as a *FieldError.
+func StructVarE(v any, fs *flag.FlagSet) error {
+	return StructVarWithOptions(v, fs, StructOptions{})
+}
+
+// StructVarWithOptions performs like StructVarE, but with additional options to
+// control how fields are registered.
+func StructVarWithOptions(v any, fs *flag.FlagSet, opts StructOptions) error {
 	if fs == nil {
 		fs = flag.CommandLine
 	}
+	if opts.Separator == "" {
+		opts.Separator = "."
+	}
+	if opts.Types == nil {
+		opts.Types = DefaultTypes
+	}
+	if opts.Naming == nil {
+		opts.Naming = LowerCase
+	}
 
 	rv := reflect.ValueOf(v)
-	if rv.IsNil() {
-		panic("expected non-nil value")
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("expected non-nil struct pointer, got: %T", v)
 	}
 	for rv.Kind() == reflect.Ptr {
 		rv = rv.Elem()
 	}
-	t := rv.Type()
-	if t.Kind() != reflect.Struct {
-		panic(fmt.Sprintf("expected value to be a struct pointer, got: %s", t.Kind().String()))
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("expected value to be a struct pointer, got: %s", rv.Kind().String())
+	}
+	hasDefaults := applyDefaults(rv, opts.Types)
+	var constraints tagConstraints
+	var registered int
+	if err := structVar(rv, fs, opts, structScope{defaults: hasDefaults, constraints: &constraints, registered: &registered}); err != nil {
+		return err
+	}
+	if err := AddConstraints(fs, constraints.list()...); err != nil {
+		return err
+	}
+	if err := checkPositional(fs); err != nil {
+		return err
 	}
+	defineNegations(fs)
+	if hasAdvancedFlags(fs) {
+		defineHelpAll(fs)
+	}
+	return nil
+}
+
+// hasAdvancedFlags returns true if any struct flag in fs is only shown by -help-all
+func hasAdvancedFlags(fs *flag.FlagSet) bool {
+	advanced := false
+	visitStructFlags(fs, func(sf *structFlag) {
+		advanced = advanced || sf.visibility == Advanced
+	})
+	return advanced
+}
+
+// structScope is what the fields of a nested struct inherit from the structs
+// containing it.
+type structScope struct {
+	prefix   string      // prepended to flag names
+	group    string      // group of fields without a group option
+	defaults bool        // true if the initial values of fields were set by a Defaulter
+	parent   *structInfo // the struct containing this one
+	variant  *variant    // optional, if this struct is a variant
+
+	constraints *tagConstraints // shared by all nested structs
+	registered  *int            // the number of flags registered, shared by all nested structs
+}
+
+// structVar registers the fields of the struct rv
+func structVar(rv reflect.Value, fs *flag.FlagSet, opts StructOptions, scope structScope) error {
+	t := rv.Type()
+	owner := &structInfo{v: rv, parent: scope.parent, variant: scope.variant}
+	prefix := scope.prefix
+	var variants []pendingVariant
 	for i, n := 0, t.NumField(); i < n; i++ {
 		f := t.Field(i)
 		if !f.IsExported() {
 			continue
 		}
-		name := strings.ToLower(f.Name)
-		defaultValue := ""
-		docstring := ""
-		var isSplat bool
-		if raw := strings.TrimSpace(f.Tag.Get("flage")); raw != "" {
-			parts := strings.SplitN(raw, ",", 3)
-			if len(parts) > 0 && parts[0] != "" {
-				if parts[0] == "*" {
-					isSplat = true
-				} else {
-					name = parts[0]
-				}
-			}
-			if len(parts) > 1 {
-				val := strings.TrimSpace(parts[1])
-				// defaultValue = parts[1]
-				if val != "" {
-					switch f.Type.Kind() {
-					case reflect.String:
-						defaultValue = parts[1]
-					default:
-						defaultValue = val
-					}
-				}
-			}
-			if len(parts) > 2 {
-				docstring = parts[2]
+		field := rv.Field(i)
+		secret := false
+		if s, ok := field.Addr().Interface().(secretHolder); ok {
+			// register the value of a Secret, as if the field had the secret option
+			field, secret = s.secretValue(), true
+			f.Type = field.Type()
+		}
+		tag := parseFieldTag(f, opts.Naming)
+		name := tag.name
+		if name == "-" {
+			continue
+		}
+		secret = secret || tag.has("secret")
+		fieldErr := func(err error) error {
+			if secret {
+				// the tag and error may include the default value
+				return &FieldError{Struct: t.Name(), Field: f.Name, Err: redact(err, tag.def)}
 			}
+			return &FieldError{Struct: t.Name(), Field: f.Name, Tag: f.Tag, Err: err}
 		}
 		numBase := 0
 		if raw := strings.TrimSpace(f.Tag.Get("flage-base")); raw != "" {
 			v, err := strconv.ParseInt(raw, 10, 64)
 			if err != nil {
-				panic(fmt.Errorf("%s flage-base tag is not an integer: %w", name, err))
+				return fieldErr(fmt.Errorf("has an invalid flage-base tag: %w", err))
+			} else if v != 0 && (v < 2 || v > 36) {
+				return fieldErr(fmt.Errorf("has an invalid flage-base tag: base must be 0 or between 2 and 36, got %d", v))
 			}
 			numBase = int(v)
 		}
-		if name == "-" {
+		_, isPos := f.Tag.Lookup(flagePosTag)
+		if _, isRest := f.Tag.Lookup(flageRestTag); isPos || isRest {
+			sf := &structFlag{field: field, fieldName: t.Name() + "." + f.Name, owner: owner, secret: secret}
+			if err := positionalVar(fs, sf, f, scope.defaults, numBase, opts); err != nil {
+				return fieldErr(err)
+			}
 			continue
 		}
+		fieldGroup := scope.group
+		if g, ok := tag.opts["group"]; ok {
+			fieldGroup = g
+		} else if tag.isSplat {
+			fieldGroup = f.Name
+		}
 
-		ptr := rv.Field(i).Addr().Interface()
-		if pt, ok := ptr.(flag.Value); ok {
-			Var(fs, pt, name, defaultValue, docstring)
-		} else if pt, ok := ptr.(encoding.TextUnmarshaler); ok {
-			TextVar(fs, pt, name, defaultValue, docstring)
-		} else {
-			switch f.Type.Kind() {
-			case reflect.Bool:
-				var def bool
-				var err error
-				if defaultValue == "" {
-					def, err = false, nil
-				} else {
-					def, err = strconv.ParseBool(defaultValue)
-				}
-				if err != nil {
-					panic(err)
-				}
-				BoolVar(fs, ptr.(*bool), name, def, docstring)
-			case reflect.String:
-				StringVar(fs, ptr.(*string), name, defaultValue, insertType("string", docstring))
-			case reflect.Int:
-				if defaultValue == "" {
-					defaultValue = "0"
-				}
-				v, err := strconv.ParseInt(defaultValue, numBase, f.Type.Bits())
-				if err != nil {
-					panic(err)
-				}
-				IntVar(fs, ptr.(*int), name, int(v), insertType("int", docstring))
-			case reflect.Int64:
-				if _, ok := ptr.(*time.Duration); ok {
-					var v time.Duration
-					if defaultValue != "" {
-						var err error
-						v, err = time.ParseDuration(defaultValue)
-						if err != nil {
-							panic(fmt.Errorf("failed to parse default value for %s: %w", name, err))
-						}
-					}
-					DurationVar(fs, ptr.(*time.Duration), name, v, insertType("int", docstring))
-				} else {
-					var v int64
-					if defaultValue != "" {
-						var err error
-						v, err = strconv.ParseInt(defaultValue, numBase, f.Type.Bits())
-						if err != nil {
-							panic(fmt.Errorf("failed to parse %s as integer (%q): %w", name, v, err))
-						}
-					}
-					Int64Var(fs, ptr.(*int64), name, v, insertType("int", docstring))
-				}
-			case reflect.Uint:
-				var v uint64
-				if defaultValue != "" {
-					var err error
-					v, err = strconv.ParseUint(defaultValue, numBase, f.Type.Bits())
-					if err != nil {
-						panic(fmt.Errorf("failed to parse default value for %s: %w", name, err))
-					}
-				}
-				UintVar(fs, ptr.(*uint), name, uint(v), insertType("uint", docstring))
-			case reflect.Uint64:
-				var v uint64
-				if defaultValue != "" {
-					var err error
-					v, err = strconv.ParseUint(defaultValue, numBase, f.Type.Bits())
-					if err != nil {
-						panic(fmt.Errorf("failed to parse default value for %s: %w", name, err))
-					}
-				}
-				Uint64Var(fs, ptr.(*uint64), name, v, insertType("uint", docstring))
-			case reflect.Float32:
-				var v float64
-				if defaultValue != "" {
-					var err error
-					v, err = strconv.ParseFloat(defaultValue, f.Type.Bits())
-					if err != nil {
-						panic(fmt.Errorf("failed to parse default value for %s: %w", name, err))
-					}
-				}
-				Float32Var(fs, ptr.(*float32), name, float32(v), insertType("float", docstring))
-			case reflect.Float64:
-				var v float64
-				if defaultValue != "" {
-					var err error
-					v, err = strconv.ParseFloat(defaultValue, f.Type.Bits())
-					if err != nil {
-						panic(fmt.Errorf("failed to parse default value for %s: %w", name, err))
-					}
+		if isNestedStruct(f.Type, opts.Types) {
+			if reflect.PointerTo(f.Type).Implements(textMarshalerType) {
+				// most likely a value type that's missing UnmarshalText
+				return fieldErr(fmt.Errorf("has an %w: %s", ErrUnsupportedType, f.Type.String()))
+			}
+			nestedPrefix := prefix + name + opts.Separator
+			if tag.isSplat || (f.Anonymous && !tag.hasName) {
+				nestedPrefix = prefix
+			}
+			nested := structScope{prefix: nestedPrefix, group: fieldGroup, defaults: scope.defaults, parent: owner, constraints: scope.constraints, registered: scope.registered}
+			if selector, ok := tag.opts["variant"]; ok {
+				if nestedPrefix == prefix || selector == "" {
+					return fieldErr(fmt.Errorf("has a variant option, but needs a flag name and a selector"))
 				}
-				Float64Var(fs, ptr.(*float64), name, v, insertType("float", docstring))
-			case reflect.Struct:
-				if isSplat {
-					StructVar(ptr, fs)
-				} else {
-					panic(fmt.Errorf("%s.%s has an unsupported type: %s", t.Name(), f.Name, f.Type.String()))
+				nested.variant = &variant{choice: name}
+				if _, hasGroup := tag.opts["group"]; !hasGroup {
+					nested.group = "-" + prefix + selector + "=" + name
 				}
-			default:
-				panic(fmt.Errorf("%s.%s has an unsupported type: %s", t.Name(), f.Name, f.Type.String()))
+				variants = append(variants, pendingVariant{variant: nested.variant, selectorName: prefix + selector, fieldErr: fieldErr})
+			}
+			if err := structVar(field, fs, opts, nested); err != nil {
+				return err
+			}
+			continue
+		} else if tag.has("variant") {
+			return fieldErr(fmt.Errorf("has a variant option, but is not a struct: %s", f.Type.String()))
+		}
+
+		name = prefix + name
+		var preset reflect.Value
+		if scope.defaults && tag.def == "" && !field.IsZero() {
+			preset = cloneValue(field)
+		}
+		value, usage, err := newFieldValue(field, tag.def, tag.docstring, numBase, opts.Types)
+		if err != nil {
+			return fieldErr(err)
+		}
+		if tag.has("counter") {
+			sv, ok := value.(*scalarValue)
+			if !ok || !isIntegerKind(f.Type.Kind()) {
+				return fieldErr(fmt.Errorf("has the counter option, but is not an integer: %s", f.Type.String()))
+			}
+			value = &counterValue{sv}
+		}
+		names := []string{name}
+		for _, alias := range tag.aliases {
+			names = append(names, prefix+alias)
+		}
+		var deprecatedNames []string
+		if raw := tag.opts["deprecated"]; raw != "" {
+			for _, alias := range strings.Split(raw, "|") {
+				deprecatedNames = append(deprecatedNames, prefix+alias)
+			}
+		}
+		for _, n := range append(names, deprecatedNames...) {
+			if fs.Lookup(n) != nil {
+				return fieldErr(fmt.Errorf("has a %w: -%s", ErrDuplicateFlag, n))
 			}
 		}
+
+		sf := &structFlag{Value: value, names: names, required: tag.has("required"), field: field, fieldName: t.Name() + "." + f.Name, owner: owner, preset: preset, secret: secret, order: *scope.registered}
+		*scope.registered++
+		sf.zero = zeroString(sf)
+		if preset.IsValid() {
+			sf.field.Set(cloneValue(preset))
+		}
+		if fieldGroup != "" {
+			sf.group = lookupGroup(fs, fieldGroup)
+		}
+		if g := tag.opts["exactlyone"]; g != "" {
+			scope.constraints.group(exactlyOne, prefix+g, name)
+		}
+		if g := tag.opts["atmostone"]; g != "" {
+			scope.constraints.group(atMostOne, prefix+g, name)
+		}
+		if raw := tag.opts["requires"]; raw != "" {
+			var others []string
+			for _, other := range strings.Split(raw, "|") {
+				others = append(others, prefix+other)
+			}
+			scope.constraints.requires(name, others)
+		}
+		if tag.has("hidden") {
+			sf.visibility = Hidden
+		} else if tag.has("advanced") {
+			sf.visibility = Advanced
+		}
+		if raw := f.Tag.Get(flageValidateTag); raw != "" {
+			if sf.validators, err = parseValidators(raw, f.Type, numBase, opts.Types); err != nil {
+				return fieldErr(fmt.Errorf("has an invalid %s tag: %w", flageValidateTag, err))
+			}
+		}
+		sf.env = strings.TrimSpace(f.Tag.Get("flage-env"))
+		if sf.env == "" && opts.EnvPrefix != "" {
+			sf.env = envName(opts.EnvPrefix, name)
+		} else if sf.env == "-" {
+			sf.env = ""
+		}
+		if sf.env != "" {
+			usage = strings.TrimSpace(usage + " [$" + sf.env + "]")
+		}
+		if sf.required {
+			usage = strings.TrimSpace(usage + " (required)")
+		}
+		if isBoolType(f.Type) && !tag.has("counter") && len(tag.name) > 1 {
+			sf.negation = prefix + "no-" + tag.name // see defineNegations
+		}
+		if mv := tag.opts["metavar"]; mv != "" {
+			sf.metavar = mv
+		} else if !strings.Contains(usage, "`") {
+			// otherwise the name in backticks is used, like flag.UnquoteUsage
+			sf.metavar = metavar(f.Type, f.Tag.Get(flageValidateTag))
+		}
+		for _, n := range names {
+			fs.Var(sf, n, usage)
+		}
+		for _, n := range deprecatedNames {
+			fs.Var(&deprecatedFlag{structFlag: sf, name: n, fs: fs, output: opts.Warnings}, n, usage)
+		}
+	}
+	return resolveVariants(fs, variants)
+}
+
+// isNestedStruct returns true if t is a struct whose fields are registered as
+// flags, instead of being a value itself.
+func isNestedStruct(t reflect.Type, types *TypeRegistry) bool {
+	if t.Kind() != reflect.Struct || isValueType(t) {
+		return false
+	}
+	_, registered := types.lookup(t)
+	return !registered
+}
+
+// isValueType returns true if a pointer to t can be used as a flag value directly.
+func isValueType(t reflect.Type) bool {
+	pt := reflect.PointerTo(t)
+	return pt.Implements(flagValueType) || pt.Implements(textUnmarshalerType)
+}
+
+// newFieldValue creates the flag.Value for the struct field at rv, along with its usage.
+func newFieldValue(rv reflect.Value, defaultValue, docstring string, numBase int, types *TypeRegistry) (flag.Value, string, error) {
+	invalidDefault := func(err error) error {
+		return fmt.Errorf("has an invalid default value %q: %w", defaultValue, err)
+	}
+	if c, ok := types.lookup(rv.Type()); ok {
+		v, err := newScalarValue(rv, c, defaultValue)
+		if err != nil {
+			return nil, "", invalidDefault(err)
+		}
+		return v, insertType(typeName(rv.Type()), docstring), nil
+	}
+	ptr := rv.Addr().Interface()
+	if pt, ok := ptr.(flag.Value); ok {
+		v, err := newFlagVar(pt, defaultValue)
+		if err != nil {
+			return nil, "", invalidDefault(err)
+		}
+		return v, docstring, nil
+	} else if pt, ok := ptr.(encoding.TextUnmarshaler); ok {
+		v, err := newTextVar(pt, defaultValue)
+		if err != nil {
+			return nil, "", invalidDefault(err)
+		}
+		return v, docstring, nil
+	}
+
+	t := rv.Type()
+	switch t.Kind() {
+	case reflect.Slice:
+		c, ok := types.codecFor(t.Elem(), numBase)
+		if !ok {
+			break
+		}
+		sv, err := newSliceValue(rv, c, defaultValue)
+		if err != nil {
+			return nil, "", invalidDefault(err)
+		}
+		return sv, insertType(t.String(), docstring), nil
+	case reflect.Map:
+		kc, kok := types.codecFor(t.Key(), numBase)
+		ec, eok := types.codecFor(t.Elem(), numBase)
+		if !kok || !eok {
+			break
+		}
+		mv, err := newMapValue(rv, kc, ec, defaultValue)
+		if err != nil {
+			return nil, "", invalidDefault(err)
+		}
+		return mv, insertType(t.String(), docstring), nil
+	case reflect.Pointer:
+		c, ok := types.codecFor(t.Elem(), numBase)
+		if !ok {
+			break
+		}
+		pv, err := newPtrValue(rv, c, defaultValue)
+		if err != nil {
+			return nil, "", invalidDefault(err)
+		}
+		return pv, insertType(typeName(t.Elem()), docstring), nil
+	default:
+		c, ok := types.codecFor(t, numBase)
+		if !ok {
+			break
+		}
+		v, err := newScalarValue(rv, c, defaultValue)
+		if err != nil {
+			return nil, "", invalidDefault(err)
+		}
+		return v, insertType(typeName(t), docstring), nil
+	}
+	return nil, "", fmt.Errorf("has an %w: %s", ErrUnsupportedType, t.String())
+}
+
+// typeName returns the name used for $type in docstrings
+func typeName(t reflect.Type) string {
+	if t == durationType {
+		return "duration"
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "int"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "uint"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.Bool, reflect.String:
+		return t.Kind().String()
+	default:
+		return t.String()
 	}
 }
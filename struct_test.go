@@ -1,9 +1,12 @@
 package flage
 
 import (
+	"bytes"
 	"encoding"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"math/big"
 	"reflect"
 	"strconv"
@@ -200,6 +203,64 @@ func TestStructVarParsingNestedStructs(t *testing.T) {
 	}
 }
 
+func TestStructVarParsingPrefixedStructs(t *testing.T) {
+	type DBConfig struct {
+		Host string `flage:",localhost"`
+		Port int    `flage-env:"DB_PORT"`
+	}
+	type Base struct {
+		Debug bool
+	}
+	type Example struct {
+		Base
+		DB      DBConfig `flage:"db"`
+		Replica DBConfig
+	}
+
+	t.Run("prefixes nested struct flags", func(t *testing.T) {
+		var example Example
+		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+		err := fs.Parse([]string{
+			"-debug",
+			"-db.host", "primary",
+			"-db.port", "5432",
+			"-replica.port", "5433",
+		})
+		if err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		expected := Example{
+			Base:    Base{Debug: true},
+			DB:      DBConfig{Host: "primary", Port: 5432},
+			Replica: DBConfig{Host: "localhost", Port: 5433},
+		}
+		if !reflect.DeepEqual(expected, example) {
+			t.Errorf("expected %#v, got %#v", expected, example)
+		}
+	})
+
+	t.Run("custom separator and env prefix", func(t *testing.T) {
+		var example Example
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		if err := StructVarWithOptions(&example, fs, StructOptions{Separator: "-", EnvPrefix: "APP_"}); err != nil {
+			t.Fatalf("failed to register flags: %s", err)
+		}
+		if err := fs.Parse([]string{"-db-host", "primary"}); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		err := ApplyEnv(fs, NewEnv(nil, EnvMap{"APP_REPLICA_HOST": {"replica"}, "DB_PORT": {"1"}}))
+		if err != nil {
+			t.Fatalf("failed to apply env: %s", err.Error())
+		}
+		if example.DB.Host != "primary" || example.Replica.Host != "replica" {
+			t.Errorf("unexpected hosts: %#v", example)
+		}
+		if example.DB.Port != 1 || example.Replica.Port != 1 {
+			t.Errorf("expected explicit env tag to be used for both ports: %#v", example)
+		}
+	})
+}
+
 func TestStructVarParsingWithDefaults(t *testing.T) {
 	type Example struct {
 		Bool bool          `flage:",true"`
@@ -268,6 +329,41 @@ func (t TypeWithNoTextUnmarshal) MarshalText() (text []byte, err error) {
 	return []byte(fmt.Sprintf("%d", t.X)), nil
 }
 
+// listValue is a flag.Value whose String panics for its zero value
+type listValue struct{ items *[]string }
+
+func (l *listValue) String() string { return strings.Join(*l.items, ",") }
+func (l *listValue) Set(s string) error {
+	if l.items == nil {
+		l.items = new([]string)
+	}
+	*l.items = append(*l.items, s)
+	return nil
+}
+
+func TestStructVarValueWithNilZeroValue(t *testing.T) {
+	type Example struct {
+		List listValue `flage:"list,a,list of items"`
+	}
+	var example Example
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := StructVarE(&example, fs); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := fs.Parse([]string{"-list", "b"}); err != nil {
+		t.Fatalf("failed to parse flags: %s", err.Error())
+	}
+	if got := example.List.String(); got != "a,b" {
+		t.Errorf("expected a,b, got %q", got)
+	}
+	var buf bytes.Buffer
+	fs.SetOutput(&buf)
+	PrintDefaults(fs)
+	if !strings.Contains(buf.String(), "list of items (default a)") {
+		t.Errorf("expected default in help, got:\n%s", buf.String())
+	}
+}
+
 func TestStructVarWithTextMarshaler(t *testing.T) {
 	t.Run("works with default values", func(t *testing.T) {
 		// defer expectPanic(t, "")
@@ -281,17 +377,19 @@ func TestStructVarWithTextMarshaler(t *testing.T) {
 			t.Errorf("failed to parse flags: %s", err.Error())
 		}
 	})
-	t.Run("panics when custom type is missing methods", func(t *testing.T) {
-		defer expectPanic(t, "Example.A has an unsupported type: ")
+	t.Run("nests custom type that is missing methods", func(t *testing.T) {
 		type Example struct {
 			A TypeWithNoImplementations
 		}
 		var example Example
 		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
-		err := fs.Parse([]string{})
+		err := fs.Parse([]string{"-a.x", "1"})
 		if err != nil {
 			t.Errorf("failed to parse flags: %s", err.Error())
 		}
+		if example.A.X != 1 {
+			t.Errorf("expected A.X to be set, got %#v", example)
+		}
 	})
 	t.Run("panics when UnmarshalText is missing", func(t *testing.T) {
 		defer expectPanic(t, "Example.A has an unsupported type")
@@ -318,6 +416,359 @@ func TestStructVarWithTextMarshaler(t *testing.T) {
 	})
 }
 
+type Port uint16
+
+func TestStructVarNumericKinds(t *testing.T) {
+	type Example struct {
+		I8   int8
+		I16  int16
+		I32  int32 `flage:",-5"`
+		U8   uint8
+		U16  uint16
+		U32  uint32
+		UPtr uintptr
+		Port Port   `flage:",8080"`
+		Hex  uint32 `flage:",ff" flage-base:"16"`
+		Name Name
+	}
+
+	var example Example
+	fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+	expected := Example{I32: -5, Port: 8080, Hex: 255}
+	if !reflect.DeepEqual(expected, example) {
+		t.Errorf("expected %#v, got %#v", expected, example)
+	}
+
+	err := fs.Parse([]string{
+		"-i8", "-128",
+		"-i16", "1000",
+		"-i32", "100000",
+		"-u8", "255",
+		"-u16", "65535",
+		"-u32", "4000000000",
+		"-uptr", "1",
+		"-port", "443",
+		"-hex", "10",
+		"-name", "bob",
+	})
+	if err != nil {
+		t.Fatalf("failed to parse flags: %s", err.Error())
+	}
+	expected = Example{
+		I8:   -128,
+		I16:  1000,
+		I32:  100000,
+		U8:   255,
+		U16:  65535,
+		U32:  4000000000,
+		UPtr: 1,
+		Port: 443,
+		Hex:  16,
+		Name: "bob",
+	}
+	if !reflect.DeepEqual(expected, example) {
+		t.Errorf("expected %#v, got %#v", expected, example)
+	}
+
+	for _, args := range [][]string{
+		{"-i8", "128"},
+		{"-u8", "256"},
+		{"-u16", "65536"},
+		{"-port", "65536"},
+	} {
+		fs.SetOutput(io.Discard)
+		err := fs.Parse(args)
+		if err == nil || !strings.Contains(err.Error(), "out of range") {
+			t.Errorf("expected out of range error for %v, got %v", args, err)
+		}
+	}
+
+	t.Run("rejects out of range defaults", func(t *testing.T) {
+		type Example struct {
+			P Port `flage:",70000"`
+		}
+		var example Example
+		if err := StructVarE(&example, flag.NewFlagSet("test", flag.ContinueOnError)); err == nil {
+			t.Error("expected error for out of range default")
+		}
+	})
+}
+
+type Name string
+
+func TestStructVarRequired(t *testing.T) {
+	type Example struct {
+		Name  string `flage:"name;required"`
+		Count int    `flage:"count;required,,number of items"`
+		Opt   bool
+	}
+
+	t.Run("reports every missing flag", func(t *testing.T) {
+		var example Example
+		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+		if err := fs.Parse([]string{"-opt"}); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		err := Check(fs)
+		if !errors.Is(err, ErrMissingRequiredFlag) {
+			t.Fatalf("expected ErrMissingRequiredFlag, got %v", err)
+		}
+		for _, name := range []string{"-name", "-count"} {
+			if !strings.Contains(err.Error(), name) {
+				t.Errorf("expected error to mention %s, got %q", name, err.Error())
+			}
+		}
+	})
+
+	t.Run("explicit zero values satisfy required", func(t *testing.T) {
+		var example Example
+		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+		if err := fs.Parse([]string{"-name", "", "-count", "0"}); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		if err := Check(fs); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("reset clears set flags", func(t *testing.T) {
+		var example Example
+		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+		if err := fs.Parse([]string{"-name", "a", "-count", "1"}); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		fs.VisitAll(func(f *flag.Flag) { Reset(f.Value) })
+		if err := Check(fs); !errors.Is(err, ErrMissingRequiredFlag) {
+			t.Errorf("expected ErrMissingRequiredFlag, got %v", err)
+		}
+	})
+}
+
+func TestStructVarPointerFields(t *testing.T) {
+	type Example struct {
+		Retries *int
+		Verbose *bool
+		Name    *string
+		Timeout *time.Duration `flage:"timeout,5s"`
+		Text    *TypeWithTextMarshals
+	}
+
+	var example Example
+	fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+	if example.Retries != nil || example.Verbose != nil || example.Name != nil || example.Text != nil {
+		t.Errorf("expected nil pointers, got %#v", example)
+	}
+	if example.Timeout == nil || *example.Timeout != 5*time.Second {
+		t.Errorf("expected default timeout, got %v", example.Timeout)
+	}
+
+	err := fs.Parse([]string{"-retries", "0", "-verbose", "-name", "", "-text", "3"})
+	if err != nil {
+		t.Fatalf("failed to parse flags: %s", err.Error())
+	}
+	if example.Retries == nil || *example.Retries != 0 {
+		t.Errorf("expected retries to be set to 0, got %v", example.Retries)
+	}
+	if example.Verbose == nil || !*example.Verbose {
+		t.Errorf("expected verbose to be set to true, got %v", example.Verbose)
+	}
+	if example.Name == nil || *example.Name != "" {
+		t.Errorf("expected name to be set to empty, got %v", example.Name)
+	}
+	if example.Text == nil || example.Text.X != 3 {
+		t.Errorf("expected text to be set, got %v", example.Text)
+	}
+
+	timeout := example.Timeout
+	fs.VisitAll(func(f *flag.Flag) { Reset(f.Value) })
+	if example.Retries != nil || example.Verbose != nil || example.Name != nil || example.Text != nil {
+		t.Errorf("expected Reset() to set nil pointers, got %#v", example)
+	}
+	if example.Timeout == timeout || *example.Timeout != 5*time.Second {
+		t.Errorf("expected Reset() to make a new pointer to the default, got %v", example.Timeout)
+	}
+}
+
+func TestStructVarAliases(t *testing.T) {
+	type Example struct {
+		Verbose bool   `flage:"verbose|v;required,,enable verbose output"`
+		Output  string `flage:"output|o,out.txt"`
+	}
+
+	var example Example
+	fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+	if err := fs.Parse([]string{"-v", "-o", "a.txt"}); err != nil {
+		t.Fatalf("failed to parse flags: %s", err.Error())
+	}
+	if !example.Verbose || example.Output != "a.txt" {
+		t.Errorf("expected aliases to set values, got %#v", example)
+	}
+	if err := Check(fs); err != nil {
+		t.Errorf("expected alias to satisfy required, got %v", err)
+	}
+	if err := fs.Parse([]string{"-output", "b.txt"}); err != nil {
+		t.Fatalf("failed to parse flags: %s", err.Error())
+	}
+	if example.Output != "b.txt" {
+		t.Errorf("expected primary name to set value, got %#v", example)
+	}
+
+	fs.VisitAll(func(f *flag.Flag) { Reset(f.Value) })
+	if example.Verbose || example.Output != "out.txt" {
+		t.Errorf("expected reset values, got %#v", example)
+	}
+	err := Check(fs)
+	if err == nil || strings.Count(err.Error(), "\n") != 0 || !strings.Contains(err.Error(), "-verbose") {
+		t.Errorf("expected a single error for the primary name, got %v", err)
+	}
+}
+
+func TestStructVarDeprecatedNames(t *testing.T) {
+	type Example struct {
+		Timeout int `flage:"timeout|t;deprecated=wait|delay,5,timeout in seconds"`
+	}
+
+	var example Example
+	var warnings, output strings.Builder
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(&output)
+	fs.Usage = func() { Usage(fs) }
+	if err := StructVarWithOptions(&example, fs, StructOptions{Warnings: &warnings}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := fs.Parse([]string{"-wait", "10"}); err != nil {
+		t.Fatalf("failed to parse flags: %s", err.Error())
+	}
+	if example.Timeout != 10 {
+		t.Errorf("expected deprecated name to set value, got %#v", example)
+	}
+	if got := warnings.String(); got != "warning: -wait is deprecated, use -timeout instead\n" {
+		t.Errorf("unexpected warning: %q", got)
+	}
+	if err := fs.Parse([]string{"-timeout", "3", "-t", "4"}); err != nil {
+		t.Fatalf("failed to parse flags: %s", err.Error())
+	}
+	if strings.Count(warnings.String(), "\n") != 1 {
+		t.Errorf("expected no warnings for current names, got %q", warnings.String())
+	}
+
+	if err := fs.Parse([]string{"-help"}); !errors.Is(err, flag.ErrHelp) {
+		t.Fatalf("expected ErrHelp, got %v", err)
+	}
+	if !strings.Contains(output.String(), "-timeout") || strings.Contains(output.String(), "wait") || strings.Contains(output.String(), "delay") {
+		t.Errorf("expected deprecated names to be hidden from help, got:\n%s", output.String())
+	}
+
+	t.Run("defaults to the flagset output", func(t *testing.T) {
+		var example Example
+		var output strings.Builder
+		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+		fs.SetOutput(&output)
+		if err := fs.Parse([]string{"-delay", "1"}); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		if !strings.Contains(output.String(), "-delay is deprecated, use -timeout") {
+			t.Errorf("expected warning on flagset output, got %q", output.String())
+		}
+	})
+
+	t.Run("rejects deprecated names that collide", func(t *testing.T) {
+		type Example struct {
+			Timeout int `flage:"timeout;deprecated=wait"`
+			Wait    int
+		}
+		var example Example
+		err := StructVarE(&example, flag.NewFlagSet("test", flag.ContinueOnError))
+		if !errors.Is(err, ErrDuplicateFlag) {
+			t.Errorf("expected ErrDuplicateFlag, got %v", err)
+		}
+	})
+}
+
+func TestStructVarE(t *testing.T) {
+	t.Run("returns field errors for invalid defaults", func(t *testing.T) {
+		type Example struct {
+			Port int `flage:"port,abc"`
+		}
+		var example Example
+		err := StructVarE(&example, flag.NewFlagSet("test", flag.ContinueOnError))
+		var fe *FieldError
+		if !errors.As(err, &fe) {
+			t.Fatalf("expected *FieldError, got %v", err)
+		}
+		if fe.Struct != "Example" || fe.Field != "Port" || fe.Tag.Get("flage") != "port,abc" {
+			t.Errorf("unexpected field error: %#v", fe)
+		}
+	})
+
+	t.Run("returns field errors for unsupported types", func(t *testing.T) {
+		type Example struct {
+			C chan int
+		}
+		var example Example
+		err := StructVarE(&example, flag.NewFlagSet("test", flag.ContinueOnError))
+		if !errors.Is(err, ErrUnsupportedType) {
+			t.Errorf("expected ErrUnsupportedType, got %v", err)
+		}
+	})
+
+	t.Run("returns field errors for invalid flage-base", func(t *testing.T) {
+		type Example struct {
+			N int `flage-base:"hex"`
+		}
+		type OutOfRange struct {
+			N int `flage-base:"40"`
+		}
+		for _, v := range []any{&Example{}, &OutOfRange{}} {
+			err := StructVarE(v, flag.NewFlagSet("test", flag.ContinueOnError))
+			var fe *FieldError
+			if !errors.As(err, &fe) || !strings.Contains(err.Error(), "flage-base") {
+				t.Errorf("%T: expected flage-base error, got %v", v, err)
+			}
+		}
+	})
+
+	t.Run("returns errors for duplicate flags from splats", func(t *testing.T) {
+		type Inner struct {
+			Name string
+		}
+		type Example struct {
+			Name  string
+			Inner Inner `flage:"*"`
+		}
+		var example Example
+		err := StructVarE(&example, flag.NewFlagSet("test", flag.ContinueOnError))
+		var fe *FieldError
+		if !errors.Is(err, ErrDuplicateFlag) || !errors.As(err, &fe) {
+			t.Fatalf("expected ErrDuplicateFlag, got %v", err)
+		}
+		if fe.Struct != "Inner" || fe.Field != "Name" {
+			t.Errorf("expected error to point at the nested field, got %#v", fe)
+		}
+	})
+
+	t.Run("returns errors for non-struct pointers", func(t *testing.T) {
+		var s string
+		if err := StructVarE(&s, nil); err == nil {
+			t.Error("expected error for string pointer")
+		}
+		if err := StructVarE(nil, nil); err == nil {
+			t.Error("expected error for nil")
+		}
+	})
+
+	t.Run("FlagSetStructE", func(t *testing.T) {
+		type Example struct {
+			Port int `flage:"port,abc"`
+		}
+		var example Example
+		fs, err := FlagSetStructE("test", flag.ContinueOnError, &example)
+		if fs != nil || err == nil {
+			t.Errorf("expected error, got %v", err)
+		}
+	})
+}
+
 func expectPanic(t *testing.T, msg string) {
 	t.Helper()
 	err := recover()
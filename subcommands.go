@@ -7,9 +7,9 @@ import (
 	"io"
 	"os"
 	"reflect"
-	"strconv"
+	"slices"
+	"sort"
 	"strings"
-	"time"
 )
 
 const flageCmdTag = "flage-cmd"
@@ -25,24 +25,47 @@ type HelpInfo struct {
 }
 
 // MakeUsageWithSubcommands creates a flag.Usage function that prints subcommands and arguments for them.
+//
+// Hidden commands and flags are never printed, and advanced ones are only
+// printed if -help-all was given. If there are advanced commands or flags, then
+// -help-all is defined on flag.CommandLine. Like other flags of flage, it's
+// only acted on by Check, so call Check(nil) after flag.Parse, which prints the
+// usage and returns flag.ErrHelp if it was given:
+//
+//	flag.Usage = flage.MakeUsageWithSubcommands(info)
+//	flag.Parse()
+//	if err := flage.Check(nil); errors.Is(err, flag.ErrHelp) {
+//		os.Exit(0)
+//	}
 func MakeUsageWithSubcommands(info HelpInfo) func() {
+	needsHelpAll := false
+	for _, cmd := range info.Commands {
+		needsHelpAll = needsHelpAll || cmd.Visibility == Advanced
+	}
+	for _, fs := range info.Flagsets {
+		needsHelpAll = needsHelpAll || fs.Lookup(helpAllName) != nil
+	}
+	if needsHelpAll {
+		defineHelpAll(flag.CommandLine)
+	}
 	return func() {
 		if info.Progname == "" {
 			info.Progname = os.Args[0]
 		}
+		all := helpAllRequested(flag.CommandLine)
 		out := flag.CommandLine.Output()
 		fmt.Fprintf(out, "Usage: %s [GLOBAL_OPTIONS] (COMMAND [COMMAND_OPTIONS])+\n", info.Progname)
 		if info.About != "" {
 			fmt.Fprintf(out, "\n%s\n", info.About)
 		}
 		fmt.Fprintf(out, "\nGLOBAL_OPTIONS:\n")
-		flag.PrintDefaults()
+		PrintDefaults(flag.CommandLine)
 		if info.CommandPrefix != "" {
 			fmt.Fprintf(out, "\n%s\n", info.CommandPrefix)
 		}
 		if !info.SkipPrintingCommands {
 			fmt.Fprintf(out, "\nCOMMANDS: (type '%s COMMAND -help' for command specific help)\n", info.Progname)
-			PrintCommands(out, info.Commands)
+			printCommands(out, info.Commands, all)
 		}
 
 		if flag.Parsed() {
@@ -60,26 +83,73 @@ func MakeUsageWithSubcommands(info HelpInfo) func() {
 			}
 		} else {
 			fmt.Fprintf(out, "FLAGS FOR ALL COMMANDS:\n")
-			PrintFlagSets(out, info.Flagsets)
+			printFlagSets(out, visibleFlagSets(info, all), all)
+		}
+	}
+}
+
+// visibleFlagSets returns the flagsets of commands that should be printed,
+// including advanced commands if all is true.
+func visibleFlagSets(info HelpInfo, all bool) []*flag.FlagSet {
+	sets := make([]*flag.FlagSet, 0, len(info.Flagsets))
+	for _, fs := range info.Flagsets {
+		visible := true
+		for _, cmd := range info.Commands {
+			if cmd.Name == fs.Name() {
+				visible = cmd.Visibility.shown(all)
+				break
+			}
+		}
+		if visible {
+			sets = append(sets, fs)
 		}
 	}
+	return sets
 }
 
 type FlagSetDefinition struct {
 	Name   string
 	Desc   string
 	OutVar any
+
+	Visibility Visibility // optional, hides the command from help
 }
 
 func NewFlagSetsAndDefsFromStruct(v any, handling flag.ErrorHandling) *FlagSetsAndDefs {
+	fss, err := NewFlagSetsAndDefsFromStructE(v, handling)
+	if err != nil {
+		panic(err)
+	}
+	return fss
+}
+
+// NewFlagSetsAndDefsFromStructE performs like NewFlagSetsAndDefsFromStruct, but
+// returns an error instead of panicking. Errors about specific fields are
+// returned as a *FieldError.
+func NewFlagSetsAndDefsFromStructE(v any, handling flag.ErrorHandling) (*FlagSetsAndDefs, error) {
+	return NewFlagSetsAndDefsFromStructWithOptions(v, handling, StructOptions{})
+}
+
+// NewFlagSetsAndDefsFromStructWithOptions performs like
+// NewFlagSetsAndDefsFromStructE, but registers the struct of each command with
+// StructVarWithOptions. Commands without a name in their flage-cmd tag are
+// named by opts.Naming.
+func NewFlagSetsAndDefsFromStructWithOptions(v any, handling flag.ErrorHandling, opts StructOptions) (*FlagSetsAndDefs, error) {
+	naming := opts.Naming
+	if naming == nil {
+		naming = LowerCase
+	}
 	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return nil, fmt.Errorf("expected non-nil struct pointer, got: %T", v)
+	}
 	t := rv.Type()
 	for t.Kind() == reflect.Ptr {
 		rv = rv.Elem()
 		t = t.Elem()
 	}
 	if t.Kind() != reflect.Struct {
-		panic(fmt.Sprintf("expected value to be a struct pointer, got: %s", t.Kind().String()))
+		return nil, fmt.Errorf("expected value to be a struct pointer, got: %s", t.Kind().String())
 	}
 	cmds := make([]FlagSetDefinition, 0, t.NumField())
 	for i, n := 0, t.NumField(); i < n; i++ {
@@ -87,12 +157,22 @@ func NewFlagSetsAndDefsFromStruct(v any, handling flag.ErrorHandling) *FlagSetsA
 		if !f.IsExported() {
 			continue
 		}
-		name := strings.ToLower(f.Name)
+		name := naming(f.Name)
 		docstring := ""
+		visibility := Visible
 		if raw := strings.TrimSpace(f.Tag.Get(flageCmdTag)); raw != "" {
 			parts := strings.SplitN(raw, ",", 3)
-			if len(parts) > 0 && parts[0] != "" {
-				name = parts[0]
+			cmdName, cmdOpts, _ := strings.Cut(parts[0], ";")
+			if cmdName != "" {
+				name = cmdName
+			}
+			for _, opt := range strings.Split(cmdOpts, ";") {
+				switch strings.TrimSpace(opt) {
+				case "hidden":
+					visibility = Hidden
+				case "advanced":
+					visibility = Advanced
+				}
 			}
 			if len(parts) > 1 {
 				docstring = strings.TrimSpace(parts[1])
@@ -105,34 +185,67 @@ func NewFlagSetsAndDefsFromStruct(v any, handling flag.ErrorHandling) *FlagSetsA
 		ptr := rv.Field(i).Addr().Interface()
 		switch f.Type.Kind() {
 		case reflect.Struct:
-			cmds = append(cmds, FlagSetDefinition{name, docstring, ptr})
+			cmds = append(cmds, FlagSetDefinition{Name: name, Desc: docstring, OutVar: ptr, Visibility: visibility})
 		default:
-			panic(fmt.Errorf("%s: unsupported field type for 'flage.NewFlagSetsAndDefsFromStruct' parsing: %s", f.Name, f.Type.Kind().String()))
+			return nil, &FieldError{
+				Struct: t.Name(),
+				Field:  f.Name,
+				Tag:    f.Tag,
+				Err:    fmt.Errorf("has an %w for 'flage.NewFlagSetsAndDefsFromStruct' parsing: %s", ErrUnsupportedType, f.Type.Kind().String()),
+			}
 		}
 	}
-	return NewFlagSets(cmds, handling)
+	return newFlagSets(cmds, handling, opts)
 }
 
 type FlagSetsAndDefs struct {
 	Defs []FlagSetDefinition
 	Sets []*flag.FlagSet
+
+	// Env, if non-nil, is applied to each command's flags after they are parsed. See ApplyEnv.
+	Env *Env
 }
 
 func NewFlagSets(defs []FlagSetDefinition, handling flag.ErrorHandling) *FlagSetsAndDefs {
+	fss, err := NewFlagSetsE(defs, handling)
+	if err != nil {
+		panic(err)
+	}
+	return fss
+}
+
+// NewFlagSetsE performs like NewFlagSets, but returns an error instead of panicking.
+func NewFlagSetsE(defs []FlagSetDefinition, handling flag.ErrorHandling) (*FlagSetsAndDefs, error) {
+	return newFlagSets(defs, handling, StructOptions{})
+}
+
+func newFlagSets(defs []FlagSetDefinition, handling flag.ErrorHandling, opts StructOptions) (*FlagSetsAndDefs, error) {
 	sets := make([]*flag.FlagSet, len(defs))
 	for i, def := range defs {
-		sets[i] = FlagSetStruct(def.Name, handling, def.OutVar)
+		fs, err := flagSetStruct(def.Name, handling, def.OutVar, opts)
+		if err != nil {
+			return nil, fmt.Errorf("command %s: %w", def.Name, err)
+		}
+		sets[i] = fs
 	}
 	return &FlagSetsAndDefs{
 		Defs: defs,
 		Sets: sets,
-	}
+	}, nil
 }
 func (fss *FlagSetsAndDefs) Parse(args []string) *CommandIterator {
-	return &CommandIterator{
-		fss,
-		newFlagSetIterator(args, fss.Sets),
+	it := newFlagSetIterator(args, fss.Sets)
+	it.afterParse = fss.afterParse
+	return &CommandIterator{fss, it}
+}
+
+func (fss *FlagSetsAndDefs) afterParse(fs *flag.FlagSet) error {
+	if fss.Env != nil {
+		if err := ApplyEnv(fs, fss.Env); err != nil {
+			return err
+		}
 	}
+	return Check(fs)
 }
 
 type CommandIterator struct {
@@ -167,25 +280,43 @@ func (fss *FlagSetsAndDefs) OutVarFromFlagset(fs *flag.FlagSet) any {
 	return nil
 }
 
-// PrintCommands prints flagset definitions
+// PrintCommands prints flagset definitions, except for hidden and advanced ones
 func PrintCommands(w io.Writer, defs []FlagSetDefinition) {
+	printCommands(w, defs, false)
+}
+
+func printCommands(w io.Writer, defs []FlagSetDefinition, all bool) {
 	maxSize := 0
 	for _, cmd := range defs {
 		s := len(cmd.Name)
-		if maxSize < s {
+		if maxSize < s && cmd.Visibility.shown(all) {
 			maxSize = s
 		}
 	}
 	for _, cmd := range defs {
+		if !cmd.Visibility.shown(all) {
+			continue
+		}
 		fmt.Fprintf(w, "  %s%s\t%s\n", cmd.Name, strings.Repeat(" ", maxSize-len(cmd.Name)), cmd.Desc)
 	}
 }
 
 // PrintFlagSets prints flagset usages with a newline separate in between
 func PrintFlagSets(w io.Writer, fss []*flag.FlagSet) {
+	printFlagSets(w, fss, false)
+}
+
+// printFlagSets performs like PrintFlagSets. If all is true, the advanced flags
+// of flagsets that define -help-all are printed too.
+func printFlagSets(w io.Writer, fss []*flag.FlagSet, all bool) {
 	for _, set := range fss {
 		fmt.Fprintf(w, "\n")
-		set.Usage()
+		if all && set.Lookup(helpAllName) != nil {
+			// StructVar only defines -help-all when it registered advanced flags
+			printUsage(set, true)
+		} else {
+			set.Usage()
+		}
 	}
 }
 
@@ -239,6 +370,8 @@ type flagSetIterator struct {
 	curr      *flag.FlagSet
 	err       error
 	parsedOne bool
+
+	afterParse func(fs *flag.FlagSet) error // optional, called after each successful FlagSet.Parse
 }
 
 func newFlagSetIterator(args []string, sets []*flag.FlagSet) *flagSetIterator {
@@ -285,9 +418,11 @@ func (it *flagSetIterator) Advance(i int) bool {
 
 // FlagSet returns the flagset that was matched from Next().
 //
-// Note: FlagSet still return all remaining args via Args() method. It is up to
-// you to parse non-flag arguments and then modify the iterator's Arg slice or
-// call Advance.
+// Note: FlagSet still return all remaining args via Args() method. Positional
+// arguments of structs with flage-pos or flage-rest fields have already been
+// consumed from the iterator, see StructVar. Otherwise, it is up to you to
+// parse non-flag arguments and then modify the iterator's Arg slice or call
+// Advance.
 func (it *flagSetIterator) FlagSet() *flag.FlagSet { return it.curr }
 
 // Next returns true if a flagset matches based on the next argument matching the flagset's name.
@@ -295,6 +430,8 @@ func (it *flagSetIterator) FlagSet() *flag.FlagSet { return it.curr }
 // Returns false that no flagset matched with an optional error via Err() which can return:
 //   - ErrNoMatchingFlagSet is returned if the iterator has consumed all args and never matched a flagset
 //   - Errors from FlagSet.Parse()
+//   - Errors from applying FlagSetsAndDefs.Env to the parsed flags
+//   - Errors from Check, such as ErrMissingRequiredFlag
 //   - ErrHelp if the FlagSet requests printing help
 //
 // Returns true if a flagset was parsed successfully.
@@ -311,7 +448,19 @@ func (it *flagSetIterator) Next() bool {
 		if it.err != nil {
 			return false
 		}
-		it.Args = it.Args[len(it.Args)-set.NArg():]
+		rest := set.Args()
+		if args := lookupArgs(set); args != nil {
+			// the arguments left over are for the next flagset
+			if rest, it.err = args.bind(rest); it.err != nil {
+				return false
+			}
+		}
+		if it.afterParse != nil {
+			if it.err = it.afterParse(set); it.err != nil {
+				return false
+			}
+		}
+		it.Args = rest
 		it.parsedOne = true
 		return true
 	} else {
@@ -325,85 +474,128 @@ func (it *flagSetIterator) Next() bool {
 // Err returns the error when Next() was called
 func (it *flagSetIterator) Err() error { return it.err }
 
-// CommandString converts a struct into a series of command line args
+// CommandString converts a struct into a series of command line args. It's the
+// inverse of StructVar: parsing the args with a flagset that a zero value of the
+// struct was registered to sets the same values.
+//
+// It uses the same flage tags, names nested structs the same way, and supports
+// the same types as StructVar. The arg tag, which older versions read names
+// from, is ignored. Only values that differ from their defaults are
+// included, in the order their fields are declared, followed by positional
+// arguments (see the flage-pos tag). Values that can't be set with flags, like
+// nil pointers or empty slices of fields with a default value, are left out.
+// Secret flags are left out, since parsing a mask would set them to it, but
+// secret positional arguments are replaced by "****" to keep the arguments
+// after them in place. The fields of variants that weren't selected are left
+// out too.
+//
+// Panics if the struct can't be registered by StructVar, or if a map has a key
+// containing "=", which can't be given as a flag.
 func CommandString(v any) []string {
+	return CommandStringWithOptions(v, StructOptions{})
+}
+
+// CommandStringWithOptions performs like CommandString, but for a struct
+// registered with StructVarWithOptions, eg - to use the same naming strategy.
+func CommandStringWithOptions(v any, opts StructOptions) []string {
 	if v == nil {
 		return nil
 	}
 	rv := reflect.ValueOf(v)
-	t := rv.Elem().Type()
-	if t.Kind() != reflect.Struct {
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
 		panic("expected value to be a struct pointer")
 	}
 
-	n := t.NumField()
-	out := make([]string, 0, n*2)
-	for i := 0; i < n; i++ {
-		f := t.Field(i)
-		name := strings.ToLower(f.Name)
-		if raw := strings.TrimSpace(f.Tag.Get("arg")); raw != "" {
-			parts := strings.SplitN(raw, ",", 2)
-			if len(parts) > 0 && parts[0] != "" {
-				name = parts[0]
-			}
+	// register a copy, whose flags format the values of v once they're copied into it
+	cp := reflect.New(rv.Elem().Type())
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	defer forgetArgs(fs)
+	if err := StructVarWithOptions(cp.Interface(), fs, opts); err != nil {
+		panic(err)
+	}
+	var sfs []*structFlag
+	defaults := make(map[*structFlag][]string)
+	visitStructFlags(fs, func(sf *structFlag) {
+		sfs = append(sfs, sf)
+		defaults[sf] = configValues(sf)
+	})
+	sort.Slice(sfs, func(i, j int) bool { return sfs[i].order < sfs[j].order })
+	args := lookupArgs(fs)
+	if args != nil {
+		for _, p := range args.all() {
+			defaults[p.structFlag] = configValues(p.structFlag)
 		}
-		if name == "-" {
+	}
+	cp.Elem().Set(rv.Elem())
+
+	out := make([]string, 0, len(sfs)*2)
+	for _, sf := range sfs {
+		values := configValues(sf)
+		if slices.Equal(values, defaults[sf]) || sf.owner.inactiveVariant() != nil || sf.secret {
 			continue
 		}
-		name = "-" + name
-		rstruct := rv.Elem()
-		switch f.Type.Kind() {
-		case reflect.Bool:
-			value := rstruct.Field(i).Bool()
-			if value {
-				out = append(out, name)
-			}
-		case reflect.String:
-			value := rstruct.Field(i).String()
-			if value != "" {
+		if m, ok := sf.Value.(*mapValue); ok {
+			checkMapKeys(sf, m)
+		}
+		name := "-" + sf.names[0]
+		for _, value := range values {
+			if !sf.IsBoolFlag() {
 				out = append(out, name, value)
+			} else if value == "true" {
+				out = append(out, name)
+			} else if value == "false" && sf.negation != "" {
+				out = append(out, "-"+sf.negation)
+			} else {
+				out = append(out, name+"="+value)
 			}
-		case reflect.Int, reflect.Int64:
-			value := rstruct.Field(i).Int()
-			if value != 0 {
-				if d, ok := rstruct.Field(i).Interface().(time.Duration); ok {
-					out = append(out, name, d.String())
-				} else {
-					out = append(out, name, strconv.FormatInt(value, 10))
-				}
-			}
-		case reflect.Uint, reflect.Uint64:
-			value := rstruct.Field(i).Uint()
-			if value != 0 {
-				out = append(out, name, strconv.FormatUint(value, 10))
-			}
-		case reflect.Slice:
-			value := rstruct.Field(i)
-			L := value.Len()
-			for j := 0; j < L; j++ {
-				val := value.Index(j)
-				switch val.Type().Kind() {
-				case reflect.Bool:
-					value := val.Bool()
-					if value {
-						out = append(out, name)
-					}
-				case reflect.String:
-					value := val.String()
-					out = append(out, name, value)
-				case reflect.Int, reflect.Int64:
-					value := val.Int()
-					out = append(out, name, strconv.FormatInt(value, 10))
-				case reflect.Uint, reflect.Uint64:
-					value := val.Uint()
-					out = append(out, name, strconv.FormatUint(value, 10))
-				default:
-					panic(fmt.Errorf("%s: unsupported field type for 'flag' emitting: %s", f.Name, f.Type.Kind().String()))
-				}
-			}
-		default:
-			panic(fmt.Errorf("%s: unsupported field type for 'flag' emitting: %s", f.Name, f.Type.Kind().String()))
 		}
 	}
+	if args != nil {
+		out = append(out, positionalStrings(args, defaults)...)
+	}
 	return out
 }
+
+// checkMapKeys panics if a key of the map of sf contains "=", since the flag
+// would parse it as the separator between the key and the value
+func checkMapKeys(sf *structFlag, m *mapValue) {
+	iter := m.ptr.MapRange()
+	for iter.Next() {
+		key := m.key.format(iter.Key())
+		if !strings.Contains(key, "=") {
+			continue
+		}
+		if sf.secret {
+			key = secretMask
+		}
+		panic(fmt.Errorf("%s has a map key that can't be given as a flag, since it contains \"=\": %q", sf.fieldName, key))
+	}
+}
+
+// positionalStrings returns the positional arguments that set the current
+// values of args. Trailing optional arguments with default values are left out.
+func positionalStrings(args *positionalArgs, defaults map[*structFlag][]string) []string {
+	var values []string
+	end := 0
+	for _, p := range args.args {
+		v := configValues(p.structFlag)
+		values = append(values, p.mask(v)...)
+		if p.required || !slices.Equal(v, defaults[p.structFlag]) {
+			end = len(values)
+		}
+	}
+	if args.rest != nil {
+		if v := configValues(args.rest.structFlag); !slices.Equal(v, defaults[args.rest.structFlag]) {
+			values = append(values, args.rest.mask(v)...)
+			end = len(values)
+		}
+	}
+	values = values[:end]
+	for _, v := range values {
+		if strings.HasPrefix(v, "-") {
+			// stop parsing flags, so the value isn't mistaken for one
+			return append([]string{"--"}, values...)
+		}
+	}
+	return values
+}
@@ -2,12 +2,16 @@ package flage
 
 import (
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
+	"math/rand"
+	"net/netip"
 	"os"
 	"reflect"
 	"strings"
 	"testing"
+	"testing/quick"
 	"time"
 )
 
@@ -106,6 +110,88 @@ func TestMakeUsageWithSubcommands(t *testing.T) {
 	})
 }
 
+func TestMakeUsageWithSubcommandsVisibility(t *testing.T) {
+	origCommandLine, origUsage := flag.CommandLine, flag.Usage
+	defer func() { flag.CommandLine, flag.Usage = origCommandLine, origUsage }()
+
+	type DeployCmd struct {
+		Env   string `flage:"env,development,environment to deploy to"`
+		Retry int    `flage:"retry;advanced,,deploy retries"`
+	}
+	type Commands struct {
+		Deploy  DeployCmd `flage-cmd:"deploy,Deploy application"`
+		Migrate struct{}  `flage-cmd:"migrate;advanced,Migrate the database"`
+		Debug   struct{}  `flage-cmd:"debug;hidden,Internal debugging"`
+	}
+
+	for _, tc := range []struct {
+		args            []string
+		shown, notShown []string
+	}{
+		{
+			args:     nil,
+			shown:    []string{"Deploy application", "-env"},
+			notShown: []string{"Migrate the database", "Internal debugging", "-retry", "help-all"},
+		},
+		{
+			args:     []string{"-help-all"},
+			shown:    []string{"Deploy application", "-env", "Migrate the database", "-retry"},
+			notShown: []string{"Internal debugging", "help-all"},
+		},
+	} {
+		flag.CommandLine = flag.NewFlagSet("myapp", flag.ContinueOnError)
+		var buf bytes.Buffer
+		flag.CommandLine.SetOutput(&buf)
+
+		var cmds Commands
+		fss := NewFlagSetsAndDefsFromStruct(&cmds, flag.ContinueOnError)
+		if fss.Defs[1].Visibility != Advanced || fss.Defs[2].Visibility != Hidden {
+			t.Errorf("unexpected visibility: %#v", fss.Defs)
+		}
+		for _, fs := range fss.Sets {
+			fs.SetOutput(&buf)
+		}
+		usage := MakeUsageWithSubcommands(HelpInfo{Commands: fss.Defs, Flagsets: fss.Sets, Progname: "myapp"})
+		// Set doesn't mark flag.CommandLine as parsed, so the flags of every command are printed
+		for _, arg := range tc.args {
+			if err := flag.CommandLine.Set(strings.TrimPrefix(arg, "-"), "true"); err != nil {
+				t.Fatalf("failed to set %s: %v", arg, err)
+			}
+		}
+		usage()
+
+		output := buf.String()
+		for _, s := range tc.shown {
+			if !strings.Contains(output, s) {
+				t.Errorf("%v: expected output to contain %q, got:\n%s", tc.args, s, output)
+			}
+		}
+		for _, s := range tc.notShown {
+			if strings.Contains(output, s) {
+				t.Errorf("%v: expected output to not contain %q, got:\n%s", tc.args, s, output)
+			}
+		}
+
+		// -help-all is only acted on by Check
+		flag.Usage = usage
+		if err := Check(nil); errors.Is(err, flag.ErrHelp) != (tc.args != nil) {
+			t.Errorf("%v: unexpected error from Check: %v", tc.args, err)
+		}
+
+		for _, fs := range fss.Sets {
+			fs.Visit(func(f *flag.Flag) { t.Errorf("%v: expected printing help to set no flags, got -%s", tc.args, f.Name) })
+			if err := Check(fs); err != nil {
+				t.Errorf("%v: unexpected error: %v", tc.args, err)
+			}
+		}
+
+		it := fss.Parse([]string{"debug"})
+		if !it.Next() {
+			t.Errorf("expected hidden command to be accepted, got %v", it.Err())
+		}
+	}
+}
+
 func TestNewFlagSetsAndDefsFromStruct(t *testing.T) {
 	type DeployCmd struct {
 		Env string `flage:"env,development,Environment to deploy to"`
@@ -195,6 +281,32 @@ func TestNewFlagSetsAndDefsFromStruct(t *testing.T) {
 	})
 }
 
+func TestNewFlagSetsAndDefsFromStructE(t *testing.T) {
+	t.Run("returns errors for unsupported command types", func(t *testing.T) {
+		type Commands struct {
+			Invalid int `flage-cmd:"invalid"`
+		}
+		_, err := NewFlagSetsAndDefsFromStructE(&Commands{}, flag.ContinueOnError)
+		if !errors.Is(err, ErrUnsupportedType) {
+			t.Errorf("Expected ErrUnsupportedType, got %v", err)
+		}
+	})
+
+	t.Run("returns errors from command flags", func(t *testing.T) {
+		type DeployCmd struct {
+			Port int `flage:"port,abc"`
+		}
+		type Commands struct {
+			Deploy DeployCmd `flage-cmd:"deploy"`
+		}
+		_, err := NewFlagSetsAndDefsFromStructE(&Commands{}, flag.ContinueOnError)
+		var fe *FieldError
+		if !errors.As(err, &fe) || fe.Field != "Port" {
+			t.Errorf("Expected field error for Port, got %v", err)
+		}
+	})
+}
+
 func TestNewFlagSets(t *testing.T) {
 	type DeployCmd struct {
 		Env string `flage:"env,development,Environment"`
@@ -295,6 +407,28 @@ func TestCommandIterator(t *testing.T) {
 		}
 	})
 
+	t.Run("checks required flags for each command", func(t *testing.T) {
+		type Required struct {
+			Target string `flage:"target;required"`
+		}
+		type RequiredCommands struct {
+			Deploy Required `flage-cmd:"deploy"`
+		}
+		cmds := &RequiredCommands{}
+		fss := NewFlagSetsAndDefsFromStruct(cmds, flag.ContinueOnError)
+
+		it := fss.Parse([]string{"deploy", "-target", "prod", "deploy"})
+		if !it.Next() {
+			t.Fatalf("Expected first command to be parsed, got %v", it.Err())
+		}
+		if it.Next() {
+			t.Error("Expected second command to fail")
+		}
+		if !errors.Is(it.Err(), ErrMissingRequiredFlag) {
+			t.Errorf("Expected ErrMissingRequiredFlag, got %v", it.Err())
+		}
+	})
+
 	t.Run("no matching flagset when no args", func(t *testing.T) {
 		cmds := &Commands{}
 		fss := NewFlagSetsAndDefsFromStruct(cmds, flag.ContinueOnError)
@@ -522,10 +656,10 @@ func TestFlagSetIterator(t *testing.T) {
 func TestCommandString(t *testing.T) {
 	t.Run("basic types", func(t *testing.T) {
 		type Flags struct {
-			Name    string `arg:"name"`
-			Verbose bool   `arg:"verbose"`
-			Count   int    `arg:"count"`
-			Port    uint   `arg:"port"`
+			Name    string `flage:"name"`
+			Verbose bool   `flage:"verbose"`
+			Count   int    `flage:"count"`
+			Port    uint   `flage:"port"`
 		}
 
 		flags := &Flags{
@@ -545,8 +679,8 @@ func TestCommandString(t *testing.T) {
 
 	t.Run("zero values omitted", func(t *testing.T) {
 		type Flags struct {
-			Name  string `arg:"name"`
-			Count int    `arg:"count"`
+			Name  string `flage:"name"`
+			Count int    `flage:"count"`
 		}
 
 		flags := &Flags{
@@ -564,7 +698,7 @@ func TestCommandString(t *testing.T) {
 
 	t.Run("duration type", func(t *testing.T) {
 		type Flags struct {
-			Timeout time.Duration `arg:"timeout"`
+			Timeout time.Duration `flage:"timeout"`
 		}
 
 		flags := &Flags{
@@ -581,8 +715,8 @@ func TestCommandString(t *testing.T) {
 
 	t.Run("slice types", func(t *testing.T) {
 		type Flags struct {
-			Tags []string `arg:"tag"`
-			IDs  []int    `arg:"id"`
+			Tags []string `flage:"tag"`
+			IDs  []int    `flage:"id"`
 		}
 
 		flags := &Flags{
@@ -598,10 +732,23 @@ func TestCommandString(t *testing.T) {
 		}
 	})
 
+	t.Run("uses the first name of aliases", func(t *testing.T) {
+		type Flags struct {
+			Verbose bool `flage:"verbose|v"`
+		}
+
+		result := CommandString(&Flags{Verbose: true})
+		expected := []string{"-verbose"}
+
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("Expected %v, got %v", expected, result)
+		}
+	})
+
 	t.Run("skip dash fields", func(t *testing.T) {
 		type Flags struct {
-			Name   string `arg:"name"`
-			Ignore string `arg:"-"`
+			Name   string `flage:"name"`
+			Ignore string `flage:"-"`
 		}
 
 		flags := &Flags{
@@ -635,9 +782,28 @@ func TestCommandString(t *testing.T) {
 		CommandString(&notStruct)
 	})
 
+	t.Run("map types in key order", func(t *testing.T) {
+		type Flags struct {
+			Labels map[string]string `flage:"label"`
+			Limits map[string]int    `flage:"limit"`
+		}
+
+		flags := &Flags{
+			Labels: map[string]string{"team": "infra", "env": "prod"},
+			Limits: map[string]int{"b": 2, "a": 1},
+		}
+
+		result := CommandString(flags)
+		expected := []string{"-label", "env=prod", "-label", "team=infra", "-limit", "a=1", "-limit", "b=2"}
+
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("Expected %v, got %v", expected, result)
+		}
+	})
+
 	t.Run("panic on unsupported type", func(t *testing.T) {
 		type Flags struct {
-			Invalid map[string]string `arg:"invalid"`
+			Invalid chan string `flage:"invalid"`
 		}
 
 		defer func() {
@@ -647,12 +813,176 @@ func TestCommandString(t *testing.T) {
 		}()
 
 		flags := &Flags{
-			Invalid: map[string]string{"key": "value"},
+			Invalid: make(chan string),
 		}
 		CommandString(flags)
 	})
 }
 
+type roundTripPort uint16
+
+type roundTripTLS struct {
+	Cert string
+	Key  *string
+}
+
+type roundTripLog struct {
+	Level   string        `flage:"level,info"`
+	Verbose bool          `flage:"verbose,true"`
+	Rotate  time.Duration `flage:"rotate,24h"`
+}
+
+type RoundTripEmbedded struct {
+	Region string
+}
+
+// roundTripFlags has a field for each kind of type StructVar supports
+type roundTripFlags struct {
+	RoundTripEmbedded
+
+	Name    string            `flage:"name|n"`
+	Enabled bool              `flage:"enabled"`
+	Cache   bool              `flage:"cache,true"`
+	Color   OptionalBool      `flage:"color"`
+	Int     int               `flage:"int,10"`
+	Int8    int8              `flage:"int8"`
+	Uint32  uint32            `flage:"uint32"`
+	Hex     uint64            `flage:"hex" flage-base:"16"`
+	Port    roundTripPort     `flage:"port,8080"`
+	F32     float32           `flage:"f32"`
+	F64     float64           `flage:"f64,1.5"`
+	Timeout time.Duration     `flage:"timeout"`
+	Addr    netip.Addr        `flage:"addr"`
+	Strs    StringSlice       `flage:"strs"`
+	Tags    []string          `flage:"tag"`
+	Waits   []time.Duration   `flage:"wait"`
+	Bools   []bool            `flage:"bool"`
+	Labels  map[string]string `flage:"label"`
+	Limits  map[string]int    `flage:"limit"`
+	Retries *int              `flage:"retries"`
+	Note    *string           `flage:"note"`
+	TLS     roundTripTLS      `flage:"tls"`
+	Log     roundTripLog      `flage:"*"`
+	Skipped string            `flage:"-"`
+}
+
+func (roundTripFlags) Generate(r *rand.Rand, size int) reflect.Value {
+	str := func() string {
+		const chars = "abc XYZ-=,'\"\\#$\t\nñ日本"
+		runes := []rune(chars)
+		b := make([]rune, r.Intn(size+1))
+		for i := range b {
+			b[i] = runes[r.Intn(len(runes))]
+		}
+		return string(b)
+	}
+	duration := func() time.Duration { return time.Duration(r.Int63n(int64(48 * time.Hour))) }
+	n := func() int { return r.Intn(4) }
+
+	f := roundTripFlags{
+		RoundTripEmbedded: RoundTripEmbedded{Region: str()},
+		Name:              str(),
+		Enabled:           r.Intn(2) == 0,
+		Cache:             r.Intn(2) == 0,
+		Int:               r.Int() - r.Int(),
+		Int8:              int8(r.Intn(256) - 128),
+		Uint32:            r.Uint32(),
+		Hex:               r.Uint64(),
+		Port:              roundTripPort(r.Intn(65536)),
+		F32:               float32(r.NormFloat64() * 1e6),
+		F64:               r.NormFloat64(),
+		Timeout:           duration(),
+		Addr:              netip.AddrFrom4([4]byte{byte(r.Intn(256)), byte(r.Intn(256)), 0, 1}),
+		Strs:              StringSlice{},
+		Tags:              []string{},
+		Waits:             []time.Duration{},
+		Bools:             []bool{},
+		Labels:            map[string]string{},
+		Limits:            map[string]int{},
+		TLS:               roundTripTLS{Cert: str()},
+		Log:               roundTripLog{Level: str(), Verbose: r.Intn(2) == 0, Rotate: duration()},
+	}
+	for i := n(); i > 0; i-- {
+		f.Strs = append(f.Strs, strings.ReplaceAll(str(), ",", ""))
+		f.Tags = append(f.Tags, str())
+		f.Waits = append(f.Waits, duration())
+		f.Bools = append(f.Bools, r.Intn(2) == 0)
+		f.Labels[str()] = str()
+		f.Limits[str()] = r.Int()
+	}
+	if r.Intn(3) != 0 {
+		f.Color = SomeBool(r.Intn(2) == 0)
+	}
+	if r.Intn(2) == 0 {
+		retries := r.Intn(10)
+		f.Retries = &retries
+	}
+	if r.Intn(2) == 0 {
+		note := str()
+		f.Note = &note
+		f.TLS.Key = &note
+	}
+	return reflect.ValueOf(f)
+}
+
+func TestCommandStringRoundTrip(t *testing.T) {
+	roundTrip := func(f roundTripFlags) (ok bool) {
+		var keys []string
+		for k := range f.Labels {
+			keys = append(keys, k)
+		}
+		for k := range f.Limits {
+			keys = append(keys, k)
+		}
+		for _, key := range keys {
+			if strings.Contains(key, "=") {
+				// the flag would parse the key up to the "=", so CommandString panics
+				defer func() { ok = recover() != nil }()
+				CommandString(&f)
+				return false
+			}
+		}
+		args := CommandString(&f)
+		var parsed roundTripFlags
+		fs := FlagSetStruct("test", flag.ContinueOnError, &parsed)
+		if err := fs.Parse(args); err != nil {
+			t.Logf("failed to parse %q: %v", args, err)
+			return false
+		}
+		if !reflect.DeepEqual(f, parsed) {
+			t.Logf("args: %q\nexpected: %#v\ngot:      %#v", args, f, parsed)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+
+	t.Run("panics for map keys with =", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), `"k=1"`) {
+				t.Errorf("expected a panic for the key, got %v", r)
+			}
+		}()
+		f := roundTripFlags{Labels: map[string]string{"k=1": "v"}}
+		CommandString(&f)
+	})
+
+	t.Run("leaves out default values", func(t *testing.T) {
+		var f roundTripFlags
+		FlagSetStruct("test", flag.ContinueOnError, &f)
+		if args := CommandString(&f); len(args) != 0 {
+			t.Errorf("expected no args for defaults, got %q", args)
+		}
+		f.Int, f.Log.Verbose = 0, false
+		expected := []string{"-int", "0", "-no-verbose"}
+		if args := CommandString(&f); !reflect.DeepEqual(args, expected) {
+			t.Errorf("expected %q, got %q", expected, args)
+		}
+	})
+}
+
 func TestFlagSetIteratorReset(t *testing.T) {
 	// This test verifies that flags are reset between command iterations
 	type Flags struct {
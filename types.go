@@ -0,0 +1,77 @@
+package flage
+
+import (
+	"reflect"
+	"sync"
+)
+
+// TypeRegistry maps types to functions that parse and format them, for types
+// that don't implement flag.Value or encoding.TextUnmarshaler (eg - *url.URL).
+//
+// Registries can be nested: types not found in a registry are looked up in its
+// parent. This allows tests to register types without modifying DefaultTypes.
+type TypeRegistry struct {
+	Parent *TypeRegistry
+
+	mu     sync.RWMutex
+	codecs map[reflect.Type]codec
+}
+
+// DefaultTypes is the registry used by StructVar and CommandString. Use
+// RegisterType to add types to it.
+var DefaultTypes = NewTypeRegistry(nil)
+
+func NewTypeRegistry(parent *TypeRegistry) *TypeRegistry {
+	return &TypeRegistry{Parent: parent}
+}
+
+// RegisterType registers parse and format functions for T in DefaultTypes.
+//
+// Example:
+//
+//	flage.RegisterType(url.Parse, (*url.URL).String)
+func RegisterType[T any](parse func(string) (T, error), format func(T) string) {
+	RegisterTypeIn(DefaultTypes, parse, format)
+}
+
+// RegisterTypeIn registers parse and format functions for T in r. Format is
+// never called with a nil value.
+func RegisterTypeIn[T any](r *TypeRegistry, parse func(string) (T, error), format func(T) string) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	c := codec{
+		parse: func(s string) (reflect.Value, error) {
+			v, err := parse(s)
+			return reflect.ValueOf(&v).Elem(), err
+		},
+		format: func(v reflect.Value) string {
+			switch v.Kind() {
+			case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+				if v.IsNil() {
+					return ""
+				}
+			}
+			x, _ := v.Interface().(T)
+			return format(x)
+		},
+		isBool: t.Kind() == reflect.Bool,
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.codecs == nil {
+		r.codecs = make(map[reflect.Type]codec)
+	}
+	r.codecs[t] = c
+}
+
+func (r *TypeRegistry) lookup(t reflect.Type) (codec, bool) {
+	for ; r != nil; r = r.Parent {
+		r.mu.RLock()
+		c, ok := r.codecs[t]
+		r.mu.RUnlock()
+		if ok {
+			return c, true
+		}
+	}
+	return codec{}, false
+}
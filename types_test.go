@@ -0,0 +1,77 @@
+package flage
+
+import (
+	"flag"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTypeRegistry(t *testing.T) {
+	types := NewTypeRegistry(DefaultTypes)
+	RegisterTypeIn(types, url.Parse, (*url.URL).String)
+	RegisterTypeIn(types, time.LoadLocation, (*time.Location).String)
+
+	type Example struct {
+		Endpoint *url.URL `flage:"endpoint,https://example.com"`
+		Mirrors  []*url.URL
+		Zone     *time.Location
+	}
+
+	var example Example
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := StructVarWithOptions(&example, fs, StructOptions{Types: types}); err != nil {
+		t.Fatalf("failed to register flags: %s", err)
+	}
+	if example.Endpoint == nil || example.Endpoint.Host != "example.com" {
+		t.Errorf("expected default endpoint, got %v", example.Endpoint)
+	}
+
+	err := fs.Parse([]string{
+		"-endpoint", "https://a.com/x",
+		"-mirrors", "https://b.com", "-mirrors", "https://c.com",
+		"-zone", "UTC",
+	})
+	if err != nil {
+		t.Fatalf("failed to parse flags: %s", err)
+	}
+	if example.Endpoint.String() != "https://a.com/x" {
+		t.Errorf("unexpected endpoint: %v", example.Endpoint)
+	}
+	if len(example.Mirrors) != 2 || example.Mirrors[1].Host != "c.com" {
+		t.Errorf("unexpected mirrors: %v", example.Mirrors)
+	}
+	if example.Zone != time.UTC {
+		t.Errorf("unexpected zone: %v", example.Zone)
+	}
+	if err := fs.Parse([]string{"-zone", "Not/AZone"}); err == nil {
+		t.Error("expected error for invalid zone")
+	}
+
+	t.Run("does not leak into the default registry", func(t *testing.T) {
+		var example Example
+		if err := StructVarE(&example, flag.NewFlagSet("test", flag.ContinueOnError)); err == nil {
+			t.Error("expected error for unregistered type")
+		}
+	})
+}
+
+func TestRegisterTypeCommandString(t *testing.T) {
+	type Celsius struct{ Degrees int }
+	RegisterType(func(s string) (Celsius, error) {
+		v, err := parseInt[int](s)
+		return Celsius{v}, err
+	}, func(c Celsius) string { return formatInt(c.Degrees) })
+	defer delete(DefaultTypes.codecs, reflect.TypeOf(Celsius{}))
+
+	type Flags struct {
+		Temp  Celsius   `flage:"temp"`
+		Temps []Celsius `flage:"temps"`
+	}
+	result := CommandString(&Flags{Temp: Celsius{20}, Temps: []Celsius{{1}, {2}}})
+	expected := []string{"-temp", "20", "-temps", "1", "-temps", "2"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+}
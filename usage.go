@@ -0,0 +1,250 @@
+package flage
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Visibility controls when a flag or command is shown in help.
+type Visibility int
+
+const (
+	Visible  Visibility = iota // always shown in help
+	Advanced                   // only shown in help when -help-all is given
+	Hidden                     // never shown in help, but still accepted
+)
+
+func (v Visibility) shown(all bool) bool {
+	return v == Visible || (v == Advanced && all)
+}
+
+const helpAllName = "help-all"
+
+// helpAllFlag is defined as -help-all when there are advanced flags or
+// commands. It's never printed in help, like -help.
+type helpAllFlag bool
+
+func (h *helpAllFlag) String() string {
+	if h == nil {
+		return "false"
+	}
+	return strconv.FormatBool(bool(*h))
+}
+
+func (h *helpAllFlag) Set(s string) error {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return errParse
+	}
+	*h = helpAllFlag(v)
+	return nil
+}
+
+func (h *helpAllFlag) IsBoolFlag() bool { return true }
+func (h *helpAllFlag) Reset()           { *h = false }
+
+// defineHelpAll defines -help-all in fs, unless a flag with that name exists
+func defineHelpAll(fs *flag.FlagSet) {
+	if fs.Lookup(helpAllName) == nil {
+		fs.Var(new(helpAllFlag), helpAllName, "show help, including advanced flags and commands")
+	}
+}
+
+// helpAllRequested returns true if -help-all was given to fs
+func helpAllRequested(fs *flag.FlagSet) bool {
+	f := fs.Lookup(helpAllName)
+	if f == nil {
+		return false
+	}
+	h, ok := f.Value.(*helpAllFlag)
+	return ok && bool(*h)
+}
+
+// PrintDefaults prints, to fs.Output(), the default values of all flags in fs
+// like flag.FlagSet.PrintDefaults. Flags registered by StructVar under multiple
+// names are printed once, with all their names: "-v, -verbose". Deprecated
+// names and hidden flags are not printed, and advanced flags are only printed
+// if -help-all was given.
+//
+// Flags without a group are printed first. Then each group is printed under
+// its title, in the order the groups were declared.
+//
+// If fs is nil, then flag.CommandLine is used instead.
+func PrintDefaults(fs *flag.FlagSet) {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	printDefaults(fs, helpAllRequested(fs))
+}
+
+// printDefaults performs like PrintDefaults, printing advanced flags if all is
+// true.
+func printDefaults(fs *flag.FlagSet, all bool) {
+	seen := make(map[*structFlag]bool)
+	var ungrouped strings.Builder
+	grouped := make(map[*flagGroup]*strings.Builder)
+	fs.VisitAll(func(f *flag.Flag) {
+		names := []string{f.Name}
+		switch f.Value.(type) {
+		case *deprecatedFlag, *negatedFlag, *helpAllFlag:
+			return
+		}
+		if sf, ok := f.Value.(*structFlag); ok {
+			if seen[sf] || !sf.visibility.shown(all) {
+				return
+			}
+			seen[sf] = true
+			names = sortedNames(sf.names)
+			if sf.negation != "" {
+				for i, name := range names {
+					if name == sf.names[0] {
+						names[i] = negatedUsageName(name, sf.negation)
+					}
+				}
+			}
+			if sf.group != nil {
+				b := grouped[sf.group]
+				if b == nil {
+					b = new(strings.Builder)
+					grouped[sf.group] = b
+				}
+				fmt.Fprint(b, flagUsage(f, names), "\n")
+				return
+			}
+		}
+		fmt.Fprint(&ungrouped, flagUsage(f, names), "\n")
+	})
+
+	out := fs.Output()
+	io.WriteString(out, ungrouped.String())
+	groups := make([]*flagGroup, 0, len(grouped))
+	for g := range grouped {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].order < groups[j].order })
+	for _, g := range groups {
+		fmt.Fprintf(out, "\n%s:\n%s", g.title, grouped[g].String())
+	}
+}
+
+// sortedNames returns the names of a flag with the shortest names first
+func sortedNames(names []string) []string {
+	names = append([]string(nil), names...)
+	sort.SliceStable(names, func(i, j int) bool { return len(names[i]) < len(names[j]) })
+	return names
+}
+
+// negatedUsageName shows a flag name along with its "no-" negation, eg -
+// "[no-]color" for "no-color", or "db.[no-]tls" for "db.no-tls".
+func negatedUsageName(name, negation string) string {
+	for i := 0; i <= len(name); i++ {
+		if negation[:i] == name[:i] && negation[i:] == "no-"+name[i:] {
+			return name[:i] + "[no-]" + name[i:]
+		}
+	}
+	return name
+}
+
+// flagUsage formats a flag like flag.FlagSet.PrintDefaults does
+func flagUsage(f *flag.Flag, names []string) string {
+	var b strings.Builder
+	b.WriteString("  -")
+	b.WriteString(strings.Join(names, ", -"))
+	name, usage := flag.UnquoteUsage(f)
+	if sf, ok := f.Value.(*structFlag); ok && name != "" && sf.metavar != "" {
+		name = sf.metavar
+	}
+	if len(name) > 0 {
+		b.WriteString(" ")
+		b.WriteString(name)
+	}
+	// Boolean flags of one ASCII letter are so common we
+	// treat them specially, putting their usage on the same line.
+	if b.Len() <= 4 { // space, space, '-', 'x'.
+		b.WriteString("\t")
+	} else {
+		// Four spaces before the tab triggers good alignment
+		// for both 4- and 8-space tab stops.
+		b.WriteString("\n    \t")
+	}
+	b.WriteString(strings.ReplaceAll(usage, "\n", "\n    \t"))
+
+	if !isZeroValue(f) {
+		if isStringFlag(f) {
+			fmt.Fprintf(&b, " (default %q)", f.DefValue)
+		} else {
+			fmt.Fprintf(&b, " (default %v)", f.DefValue)
+		}
+	}
+	return b.String()
+}
+
+// isStringFlag returns true if the default value of the flag should be quoted
+func isStringFlag(f *flag.Flag) bool {
+	if sf, ok := f.Value.(*structFlag); ok {
+		return sf.field.IsValid() && sf.field.Kind() == reflect.String
+	}
+	return reflect.TypeOf(f.Value).String() == "*flag.stringValue"
+}
+
+// isZeroValue determines whether the default value of a flag is the zero
+// value, the same way the flag package does.
+func isZeroValue(f *flag.Flag) (ok bool) {
+	if sf, ok := f.Value.(*structFlag); ok {
+		return f.DefValue == sf.zero
+	}
+	defer func() {
+		if recover() != nil {
+			ok = f.DefValue == ""
+		}
+	}()
+	typ := reflect.TypeOf(f.Value)
+	var z reflect.Value
+	if typ.Kind() == reflect.Pointer {
+		z = reflect.New(typ.Elem())
+	} else {
+		z = reflect.Zero(typ)
+	}
+	return f.DefValue == z.Interface().(flag.Value).String()
+}
+
+// Usage prints help for fs to fs.Output() like flag.FlagSet.Usage does, but with
+// PrintDefaults, which knows about the options of StructVar. Advanced flags are
+// included if -help-all was given. FlagSetStruct uses it for the Usage of its
+// flagsets. Other flagsets, including flag.CommandLine, need to opt in:
+//
+//	flag.Usage = func() { flage.Usage(nil) }
+//
+// If fs is nil, then flag.CommandLine is used instead.
+func Usage(fs *flag.FlagSet) {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	defaultUsage(fs)
+}
+
+// defaultUsage is used as the Usage of flagsets created by FlagSetStruct
+func defaultUsage(fs *flag.FlagSet) {
+	printUsage(fs, helpAllRequested(fs))
+}
+
+// printUsage performs like defaultUsage, printing advanced flags if all is true
+func printUsage(fs *flag.FlagSet, all bool) {
+	if lookupArgs(fs) != nil {
+		// show the positional arguments in a synopsis, eg - "deploy [flags] <source>"
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage: %s\n\nArguments:\n", strings.TrimSpace(fs.Name()+" "+synopsis(fs)))
+		printArguments(out, fs)
+		fmt.Fprintf(out, "\nFlags:\n")
+	} else if fs.Name() == "" {
+		fmt.Fprintf(fs.Output(), "Usage:\n")
+	} else {
+		fmt.Fprintf(fs.Output(), "Usage of %s:\n", fs.Name())
+	}
+	printDefaults(fs, all)
+}
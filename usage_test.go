@@ -0,0 +1,219 @@
+package flage
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrintDefaults(t *testing.T) {
+	t.Run("matches flag.PrintDefaults for plain flags", func(t *testing.T) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.String("name", "bob", "the `user` name")
+		fs.Bool("v", false, "verbose")
+		fs.Int("count", 0, "number of items")
+
+		var expected, actual bytes.Buffer
+		fs.SetOutput(&expected)
+		fs.PrintDefaults()
+		fs.SetOutput(&actual)
+		PrintDefaults(fs)
+		if expected.String() != actual.String() {
+			t.Errorf("expected:\n%s\ngot:\n%s", expected.String(), actual.String())
+		}
+	})
+
+	t.Run("groups aliases on one line", func(t *testing.T) {
+		type Example struct {
+			Verbose bool   `flage:"verbose|v,,enable verbose output"`
+			Output  string `flage:"output|o|out,out.txt,output file"`
+		}
+		var example Example
+		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+		var buf bytes.Buffer
+		fs.SetOutput(&buf)
+		fs.Usage()
+
+		output := buf.String()
+		for _, s := range []string{
+			"Usage of test:\n",
+			"  -o, -out, -output STRING\n    \toutput file (default \"out.txt\")\n",
+			"  -v, -[no-]verbose\n    \tenable verbose output\n",
+		} {
+			if !strings.Contains(output, s) {
+				t.Errorf("expected output to contain %q, got:\n%s", s, output)
+			}
+		}
+		if strings.Count(output, "verbose output") != 1 {
+			t.Errorf("expected aliases to be printed once, got:\n%s", output)
+		}
+	})
+
+	t.Run("hides hidden and advanced flags", func(t *testing.T) {
+		type Example struct {
+			Name  string `flage:"name,,the name"`
+			Debug bool   `flage:"debug;hidden,,internal debugging"`
+			Tune  int    `flage:"tune;advanced,3,tuning knob"`
+		}
+		var example Example
+		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+		var buf bytes.Buffer
+		fs.SetOutput(&buf)
+		if err := fs.Parse([]string{"-debug", "-tune", "4"}); err != nil {
+			t.Fatalf("expected hidden and advanced flags to be accepted, got %v", err)
+		}
+		if !example.Debug || example.Tune != 4 {
+			t.Errorf("expected values to be set, got %#v", example)
+		}
+		fs.Usage()
+		if output := buf.String(); !strings.Contains(output, "-name") || strings.Contains(output, "debug") || strings.Contains(output, "tune") || strings.Contains(output, "help-all") {
+			t.Errorf("expected only visible flags, got:\n%s", output)
+		}
+
+		buf.Reset()
+		if err := fs.Parse([]string{"-help-all"}); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		if err := Check(fs); !errors.Is(err, flag.ErrHelp) {
+			t.Errorf("expected ErrHelp from Check, got %v", err)
+		}
+		if output := buf.String(); !strings.Contains(output, "tuning knob (default 3)") || strings.Contains(output, "debug") {
+			t.Errorf("expected advanced flags but not hidden ones, got:\n%s", output)
+		}
+	})
+
+	t.Run("prints groups as sections in declaration order", func(t *testing.T) {
+		type TLS struct {
+			Cert string `flage:"cert,,certificate file"`
+			Key  string `flage:"key,,key file"`
+		}
+		type Log struct {
+			Level string `flage:"level,info,log level"`
+			Trace bool   `flage:"trace;hidden"`
+		}
+		type Example struct {
+			Verbose bool   `flage:"verbose,,enable verbose output"`
+			Port    int    `flage:"port;group=Networking,80,port to listen on"`
+			Log     Log    `flage:"log;group=Logging"`
+			Host    string `flage:"host;group=Networking,,host to listen on"`
+			TLS     TLS    `flage:"*"`
+			Debug   Log    `flage:"debug;group=Logging"`
+		}
+		var example Example
+		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+		var buf bytes.Buffer
+		fs.SetOutput(&buf)
+		fs.Usage()
+
+		expected := "Usage of test:\n" +
+			"  -[no-]verbose\n    \tenable verbose output\n" +
+			"\nNetworking:\n" +
+			"  -host STRING\n    \thost to listen on\n" +
+			"  -port INT\n    \tport to listen on (default 80)\n" +
+			"\nLogging:\n" +
+			"  -debug.level STRING\n    \tlog level (default \"info\")\n" +
+			"  -log.level STRING\n    \tlog level (default \"info\")\n" +
+			"\nTLS:\n" +
+			"  -cert STRING\n    \tcertificate file\n" +
+			"  -key STRING\n    \tkey file\n"
+		if buf.String() != expected {
+			t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+		}
+	})
+
+	t.Run("prints metavars for values", func(t *testing.T) {
+		type Port uint16
+		type Example struct {
+			Config  string            `flage:"config;metavar=FILE,,config to load"`
+			Timeout time.Duration     `flage:"timeout"`
+			Labels  map[string]string `flage:"label"`
+			Format  string            `flage:"format,json" flage-validate:"oneof=json|yaml|text"`
+			Dir     string            `flage:"dir" flage-validate:"file-exists"`
+			Hosts   []string          `flage:"host"`
+			Retries *int              `flage:"retries"`
+			Port    Port              `flage:"port"`
+			Addr    netip.Addr        `flage:"addr"`
+			Tags    StringSlice       `flage:"tag"`
+			Name    string            "flage:\"name,,the `user` name\""
+			Rate    float64           `flage:"rate,,a $type"`
+			Force   bool              `flage:"force"`
+		}
+		var example Example
+		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+		var buf bytes.Buffer
+		fs.SetOutput(&buf)
+		PrintFlagSets(&buf, []*flag.FlagSet{fs})
+
+		for _, s := range []string{
+			"  -config FILE\n",
+			"  -timeout DURATION\n",
+			"  -label KEY=VALUE\n",
+			"  -format {json|yaml|text}\n",
+			"  -dir PATH\n",
+			"  -host STRING\n",
+			"  -retries INT\n",
+			"  -port PORT\n",
+			"  -addr ADDR\n",
+			"  -tag VALUE\n",
+			"  -name user\n    \tthe user name\n",
+			"  -rate float\n    \ta float\n",
+			"  -[no-]force\n",
+		} {
+			if !strings.Contains(buf.String(), s) {
+				t.Errorf("expected output to contain %q, got:\n%s", s, buf.String())
+			}
+		}
+	})
+
+	t.Run("hides hidden flags from the usage of flag.CommandLine", func(t *testing.T) {
+		type Example struct {
+			Name  string `flage:"name,,the name"`
+			Trace bool   `flage:"trace;hidden"`
+		}
+		commandLine, usage := flag.CommandLine, flag.Usage
+		defer func() { flag.CommandLine, flag.Usage = commandLine, usage }()
+		flag.CommandLine = flag.NewFlagSet("app", flag.ContinueOnError)
+		flag.CommandLine.Usage = func() { flag.Usage() } // like the flag package does
+		var buf bytes.Buffer
+		flag.CommandLine.SetOutput(&buf)
+
+		custom := false
+		flag.Usage = func() { custom = true }
+		var example Example
+		StructVar(&example, nil)
+		if flag.Usage(); !custom {
+			t.Errorf("expected StructVar to leave flag.Usage alone")
+		}
+		flag.Usage = func() { Usage(nil) }
+		if err := flag.CommandLine.Parse([]string{"-help"}); !errors.Is(err, flag.ErrHelp) {
+			t.Fatalf("expected ErrHelp, got %v", err)
+		}
+		if output := buf.String(); !strings.Contains(output, "Usage of app:\n") || !strings.Contains(output, "-name") || strings.Contains(output, "trace") {
+			t.Errorf("expected only visible flags, got:\n%s", output)
+		}
+
+		custom = false
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Usage = func() { custom = true }
+		StructVar(&example, fs)
+		fs.Usage()
+		if !custom {
+			t.Errorf("expected custom usage to be kept")
+		}
+	})
+
+	t.Run("only defines -help-all for advanced flags", func(t *testing.T) {
+		type Example struct {
+			Debug bool `flage:"debug;hidden"`
+		}
+		var example Example
+		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+		if fs.Lookup("help-all") != nil {
+			t.Errorf("expected -help-all to not be defined")
+		}
+	})
+}
@@ -0,0 +1,276 @@
+package flage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const flageValidateTag = "flage-validate"
+
+// ValidationError is returned by Check for each flag whose value violates one
+// of the constraints in its flage-validate tag. It can also be returned by the
+// Validate method of a struct, see Validator.
+type ValidationError struct {
+	Flag  string // name of the flag, without the leading "-"
+	Field string // name of the struct field, eg - "Config.Port"
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	if e.Flag == "" {
+		return fmt.Sprintf("invalid value for %s: %s", e.Field, e.Err)
+	}
+	return fmt.Sprintf("invalid value for -%s (%s): %s", e.Flag, e.Field, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// validator checks the value of a struct field
+type validator func(v reflect.Value) error
+
+// parseValidators parses the flage-validate tag of a field of type t into
+// validators. See Check for the supported constraints.
+func parseValidators(raw string, t reflect.Type, base int, types *TypeRegistry) ([]validator, error) {
+	var validators []validator
+	for _, rule := range splitRules(raw) {
+		v, err := parseValidator(rule, t, base, types)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", rule, err)
+		}
+		validators = append(validators, v)
+	}
+	return validators, nil
+}
+
+// splitRules splits a flage-validate tag into its comma separated rules. A
+// regex rule is always last, since the expression may contain commas.
+func splitRules(raw string) []string {
+	var rules []string
+	for raw = strings.TrimSpace(raw); raw != ""; {
+		var rule string
+		if strings.HasPrefix(raw, "regex=") {
+			rule, raw = raw, ""
+		} else {
+			rule, raw, _ = strings.Cut(raw, ",")
+			raw = strings.TrimSpace(raw)
+		}
+		if rule = strings.TrimSpace(rule); rule != "" {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+func parseValidator(rule string, t reflect.Type, base int, types *TypeRegistry) (validator, error) {
+	switch {
+	case rule == "nonempty":
+		return func(v reflect.Value) error {
+			switch v.Kind() {
+			case reflect.String, reflect.Slice, reflect.Map:
+				if v.Len() == 0 {
+					return errors.New("must not be empty")
+				}
+			case reflect.Pointer, reflect.Interface:
+				if v.IsNil() {
+					return errors.New("must not be empty")
+				}
+			default:
+				if v.IsZero() {
+					return errors.New("must not be empty")
+				}
+			}
+			return nil
+		}, nil
+	case strings.HasPrefix(rule, "len"):
+		return parseLenValidator(rule, t)
+	}
+
+	// the remaining rules apply to elements
+	elem := t
+	for elem.Kind() == reflect.Pointer || elem.Kind() == reflect.Slice || elem.Kind() == reflect.Map {
+		if _, ok := types.lookup(elem); ok {
+			break
+		}
+		elem = elem.Elem()
+	}
+	c, ok := types.codecFor(elem, base)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, elem)
+	}
+
+	key, arg, _ := strings.Cut(rule, "=")
+	var check validator
+	switch key {
+	case "min", "max":
+		bound, err := c.parse(arg)
+		if err != nil {
+			return nil, err
+		}
+		cmp, ok := compareFunc(elem)
+		if !ok {
+			return nil, fmt.Errorf("%w for %s: %s", ErrUnsupportedType, key, elem)
+		}
+		if key == "min" {
+			check = func(v reflect.Value) error {
+				if cmp(v, bound) < 0 {
+					return fmt.Errorf("must be at least %s", arg)
+				}
+				return nil
+			}
+		} else {
+			check = func(v reflect.Value) error {
+				if cmp(v, bound) > 0 {
+					return fmt.Errorf("must be at most %s", arg)
+				}
+				return nil
+			}
+		}
+	case "oneof":
+		choices := strings.Split(arg, "|")
+		check = func(v reflect.Value) error {
+			s := c.format(v)
+			for _, choice := range choices {
+				if s == choice {
+					return nil
+				}
+			}
+			return fmt.Errorf("%q must be one of %s", s, strings.Join(choices, ", "))
+		}
+	case "regex":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, err
+		}
+		check = func(v reflect.Value) error {
+			if s := c.format(v); !re.MatchString(s) {
+				return fmt.Errorf("%q does not match %s", s, arg)
+			}
+			return nil
+		}
+	case "file-exists":
+		check = func(v reflect.Value) error {
+			s := c.format(v)
+			if info, err := os.Stat(s); err != nil {
+				return fmt.Errorf("file %q does not exist", s)
+			} else if info.IsDir() {
+				return fmt.Errorf("%q is a directory", s)
+			}
+			return nil
+		}
+	default:
+		return nil, errors.New("unknown constraint")
+	}
+	return eachElem(check, t, elem), nil
+}
+
+// eachElem applies check to every element of v, until it reaches the type elem.
+func eachElem(check validator, t, elem reflect.Type) validator {
+	if t == elem {
+		return check
+	}
+	inner := eachElem(check, t.Elem(), elem)
+	switch t.Kind() {
+	case reflect.Pointer:
+		return func(v reflect.Value) error {
+			if v.IsNil() {
+				return nil
+			}
+			return inner(v.Elem())
+		}
+	case reflect.Slice:
+		return func(v reflect.Value) error {
+			for i, n := 0, v.Len(); i < n; i++ {
+				if err := inner(v.Index(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	default: // map
+		return func(v reflect.Value) error {
+			iter := v.MapRange()
+			for iter.Next() {
+				if err := inner(iter.Value()); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+}
+
+func compareFunc(t reflect.Type) (func(a, b reflect.Value) int, bool) {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(a, b reflect.Value) int { return compare(a.Int(), b.Int()) }, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(a, b reflect.Value) int { return compare(a.Uint(), b.Uint()) }, true
+	case reflect.Float32, reflect.Float64:
+		return func(a, b reflect.Value) int { return compare(a.Float(), b.Float()) }, true
+	default:
+		return nil, false
+	}
+}
+
+func compare[X int64 | uint64 | float64](a, b X) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func parseLenValidator(rule string, t reflect.Type) (validator, error) {
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+	default:
+		return nil, fmt.Errorf("%w for len: %s", ErrUnsupportedType, t)
+	}
+	rest := strings.TrimPrefix(rule, "len")
+	var op string
+	for _, candidate := range []string{"<=", ">=", "<", ">", "="} {
+		if strings.HasPrefix(rest, candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		return nil, errors.New("expected len<=N, len>=N, len<N, len>N or len=N")
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(rest[len(op):]))
+	if err != nil {
+		return nil, err
+	}
+	return func(v reflect.Value) error {
+		size := v.Len()
+		if v.Kind() == reflect.String {
+			size = utf8.RuneCountInString(v.String())
+		}
+		var ok bool
+		switch op {
+		case "<=":
+			ok = size <= n
+		case ">=":
+			ok = size >= n
+		case "<":
+			ok = size < n
+		case ">":
+			ok = size > n
+		default:
+			ok = size == n
+		}
+		if !ok {
+			return fmt.Errorf("length must be %s %d, got %d", op, n, size)
+		}
+		return nil
+	}, nil
+}
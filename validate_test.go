@@ -0,0 +1,110 @@
+package flage
+
+import (
+	"errors"
+	"flag"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckValidation(t *testing.T) {
+	type Example struct {
+		Port    int           `flage:"port,8080" flage-validate:"min=1,max=65535"`
+		Level   string        `flage:"level,info" flage-validate:"oneof=debug|info|warn"`
+		Name    string        `flage-validate:"nonempty,len<=8,regex=^[a-z]+$"`
+		Timeout time.Duration `flage:"timeout,1s" flage-validate:"min=1s"`
+		Hosts   []string      `flage-validate:"len<=2,oneof=a|b|c"`
+		Retries *int          `flage-validate:"min=0"`
+		Config  string        `flage-validate:"file-exists"`
+	}
+
+	dir := t.TempDir()
+	valid := []string{"-name", "abc", "-config", "validate_test.go"}
+
+	t.Run("passes for valid values", func(t *testing.T) {
+		var example Example
+		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+		if err := fs.Parse(valid); err != nil {
+			t.Fatalf("failed to parse flags: %s", err)
+		}
+		if err := Check(fs); err != nil {
+			t.Errorf("expected no errors, got %v", err)
+		}
+	})
+
+	t.Run("reports every violation", func(t *testing.T) {
+		var example Example
+		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+		err := fs.Parse([]string{
+			"-port", "0",
+			"-level", "trace",
+			"-name", "ABCDEFGHIJ",
+			"-timeout", "0s",
+			"-hosts", "a", "-hosts", "d",
+			"-retries", "-1",
+			"-config", filepath.Join(dir, "missing"),
+		})
+		if err != nil {
+			t.Fatalf("failed to parse flags: %s", err)
+		}
+		err = Check(fs)
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Fatalf("expected *ValidationError, got %v", err)
+		}
+		for _, msg := range []string{
+			"-port (Example.Port): must be at least 1",
+			`-level (Example.Level): "trace" must be one of debug, info, warn`,
+			"-name (Example.Name): length must be <= 8",
+			`-name (Example.Name): "ABCDEFGHIJ" does not match ^[a-z]+$`,
+			"-timeout (Example.Timeout): must be at least 1s",
+			`-hosts (Example.Hosts): "d" must be one of a, b, c`,
+			"-retries (Example.Retries): must be at least 0",
+			"-config (Example.Config): file",
+		} {
+			if !strings.Contains(err.Error(), msg) {
+				t.Errorf("expected error to contain %q, got:\n%s", msg, err)
+			}
+		}
+	})
+
+	t.Run("validates defaults", func(t *testing.T) {
+		type Defaults struct {
+			Port int `flage:"port,70000" flage-validate:"max=65535"`
+		}
+		var example Defaults
+		fs := FlagSetStruct("test", flag.ContinueOnError, &example)
+		if err := fs.Parse(nil); err != nil {
+			t.Fatalf("failed to parse flags: %s", err)
+		}
+		if err := Check(fs); err == nil {
+			t.Error("expected default value to be validated")
+		}
+	})
+
+	t.Run("rejects invalid tags", func(t *testing.T) {
+		for _, tc := range []struct {
+			name string
+			v    any
+		}{
+			{"unknown", &struct {
+				A int `flage-validate:"positive"`
+			}{}},
+			{"min on string", &struct {
+				A string `flage-validate:"min=1"`
+			}{}},
+			{"len on int", &struct {
+				A int `flage-validate:"len<3"`
+			}{}},
+			{"bad regex", &struct {
+				A string `flage-validate:"regex=("`
+			}{}},
+		} {
+			if err := StructVarE(tc.v, flag.NewFlagSet("test", flag.ContinueOnError)); err == nil {
+				t.Errorf("%s: expected error", tc.name)
+			}
+		}
+	})
+}
@@ -5,15 +5,44 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"reflect"
 	"strconv"
 	"time"
+	"unsafe"
 
 	"golang.org/x/exp/constraints"
 )
 
-var errParse = errors.New("parse error")
+var (
+	errParse = errors.New("parse error")
+	errRange = errors.New("value out of range")
+)
+
+// numError mirrors the flag package by hiding the details of strconv errors.
+func numError(err error) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return errRange
+	}
+	return errParse
+}
+
+// valueOrigin records where the value of a flag defined by this package came
+// from, like structFlag does for StructVar, so that Provenance can report it.
+type valueOrigin struct {
+	set    bool
+	origin Origin
+}
+
+func (o *valueOrigin) lastOrigin() (Origin, bool) { return o.origin, o.set }
+
+// originTracker is implemented by values that record where they were set from
+type originTracker interface {
+	setFrom(s string, origin Origin) error
+	lastOrigin() (Origin, bool)
+}
 
 type resettableValue[T any] struct {
+	valueOrigin
 	ptr      *T
 	defvalue T
 	parser   func(string) (T, error)
@@ -26,12 +55,16 @@ func (b *resettableValue[T]) Set(s string) error {
 	if b == nil {
 		return nil
 	}
+	return b.setFrom(s, Origin{Source: CommandLine, Raw: s})
+}
+func (b *resettableValue[T]) setFrom(s string, origin Origin) error {
 	v, err := b.parser(s)
 	if err != nil {
-		err = errParse
+		return numError(err)
 	}
 	*b.ptr = v
-	return err
+	b.valueOrigin = valueOrigin{set: true, origin: origin}
+	return nil
 }
 func (b *resettableValue[T]) Get() any { return T(*b.ptr) }
 func (b *resettableValue[T]) String() string {
@@ -40,7 +73,10 @@ func (b *resettableValue[T]) String() string {
 	}
 	return b.stringer(*b.ptr)
 }
-func (b *resettableValue[T]) Reset() { *b.ptr = b.defvalue }
+func (b *resettableValue[T]) Reset() {
+	*b.ptr = b.defvalue
+	b.valueOrigin = valueOrigin{}
+}
 
 func newVar[T any](ptr *T, defvalue T, parser func(string) (T, error), stringer func(T) string, isBool bool) *resettableValue[T] {
 	*ptr = defvalue
@@ -49,9 +85,22 @@ func newVar[T any](ptr *T, defvalue T, parser func(string) (T, error), stringer
 
 type resettableFlagVar struct {
 	flag.Value
+	valueOrigin
 	defval string
 }
 
+func (b *resettableFlagVar) Set(s string) error {
+	return b.setFrom(s, Origin{Source: CommandLine, Raw: s})
+}
+
+func (b *resettableFlagVar) setFrom(s string, origin Origin) error {
+	if err := b.Value.Set(s); err != nil {
+		return err
+	}
+	b.valueOrigin = valueOrigin{set: true, origin: origin}
+	return nil
+}
+
 func (b *resettableFlagVar) String() string {
 	if b == nil {
 		return ""
@@ -62,41 +111,60 @@ func (b *resettableFlagVar) String() string {
 	return b.Value.String()
 }
 
+// IsBoolFlag forwards to the wrapped value, so that types like Counter can be
+// given without a value.
+func (b *resettableFlagVar) IsBoolFlag() bool {
+	v, ok := b.Value.(interface{ IsBoolFlag() bool })
+	return ok && v.IsBoolFlag()
+}
+
 func (b *resettableFlagVar) Reset() {
 	if v, ok := b.Value.(resetable); ok {
 		v.Reset()
 	} else {
-		err := b.Set(b.defval)
+		err := b.Value.Set(b.defval)
 		if err != nil {
 			panic(fmt.Errorf("failed to set flag value: %w", err))
 		}
 	}
+	b.valueOrigin = valueOrigin{}
 }
 
 func Var(fs *flag.FlagSet, p flag.Value, name string, value string, usage string) {
+	v, err := newFlagVar(p, value)
+	if err != nil {
+		panic(fmt.Errorf("failed to set flag value: %w", err))
+	}
+	fs.Var(v, name, usage)
+}
+
+func newFlagVar(p flag.Value, value string) (*resettableFlagVar, error) {
 	if v, ok := p.(resetable); ok {
 		v.Reset()
-	} else {
-		err := p.Set(value)
-		if err != nil {
-			panic(fmt.Errorf("failed to set flag value: %w", err))
-		}
+	} else if err := p.Set(value); err != nil {
+		return nil, err
 	}
-	fs.Var(&resettableFlagVar{p, value}, name, usage)
+	return &resettableFlagVar{Value: p, defval: value}, nil
 }
 
 func BoolVar(fs *flag.FlagSet, p *bool, name string, value bool, usage string) {
 	fs.Var(newVar(p, value, strconv.ParseBool, strconv.FormatBool, true), name, usage)
 }
 
+// bitSize returns the size of X in bits, so that parsing can reject values that don't fit.
+func bitSize[X constraints.Integer | constraints.Float]() int {
+	var x X
+	return int(unsafe.Sizeof(x)) * 8
+}
+
 func parseInt[X constraints.Integer](s string) (X, error) {
-	v, err := strconv.ParseInt(s, 0, 64)
+	v, err := strconv.ParseInt(s, 0, bitSize[X]())
 	return X(v), err
 }
 func formatInt[X constraints.Integer](v X) string { return strconv.FormatInt(int64(v), 10) }
 
 func parseUint[X constraints.Unsigned](s string) (X, error) {
-	v, err := strconv.ParseUint(s, 0, strconv.IntSize)
+	v, err := strconv.ParseUint(s, 0, bitSize[X]())
 	return X(v), err
 }
 func formatUint[X constraints.Integer](v X) string { return strconv.FormatUint(uint64(v), 10) }
@@ -115,7 +183,7 @@ func Uint64Var(fs *flag.FlagSet, p *uint64, name string, value uint64, usage str
 }
 
 func parseFloat[X constraints.Float](s string) (X, error) {
-	v, err := strconv.ParseFloat(s, 64)
+	v, err := strconv.ParseFloat(s, bitSize[X]())
 	return X(v), err
 }
 func formatFloat[X constraints.Float](v X) string {
@@ -141,6 +209,7 @@ func DurationVar(fs *flag.FlagSet, p *time.Duration, name string, value time.Dur
 }
 
 type textMarshalVar struct {
+	valueOrigin
 	ptr      encoding.TextUnmarshaler
 	defvalue string
 }
@@ -155,8 +224,17 @@ func textMarshal(m any, s string) string {
 	return ""
 }
 
-func (b *textMarshalVar) Set(s string) error { return b.ptr.UnmarshalText([]byte(s)) }
-func (b *textMarshalVar) Get() any           { return b.ptr }
+func (b *textMarshalVar) Set(s string) error {
+	return b.setFrom(s, Origin{Source: CommandLine, Raw: s})
+}
+func (b *textMarshalVar) setFrom(s string, origin Origin) error {
+	if err := b.ptr.UnmarshalText([]byte(s)); err != nil {
+		return err
+	}
+	b.valueOrigin = valueOrigin{set: true, origin: origin}
+	return nil
+}
+func (b *textMarshalVar) Get() any { return b.ptr }
 func (b *textMarshalVar) String() string {
 	if b == nil {
 		return ""
@@ -168,13 +246,241 @@ func (b *textMarshalVar) Reset() {
 	if err != nil {
 		panic(fmt.Errorf("failed to reset value: %w", err))
 	}
+	b.valueOrigin = valueOrigin{}
 }
 
 func TextVar(fs *flag.FlagSet, p encoding.TextUnmarshaler, name string, value string, usage string) {
+	v, err := newTextVar(p, value)
+	if err != nil {
+		panic(fmt.Errorf("failed to set flag value %q: %w", name, err))
+	}
+	fs.Var(v, name, usage)
+}
+
+func newTextVar(p encoding.TextUnmarshaler, value string) (*textMarshalVar, error) {
 	if value != "" {
 		if err := p.UnmarshalText([]byte(value)); err != nil {
-			panic(fmt.Errorf("failed to set flag value %q: %w", name, err))
+			return nil, err
+		}
+	}
+	return &textMarshalVar{ptr: p, defvalue: value}, nil
+}
+
+var (
+	flagValueType       = reflect.TypeOf((*flag.Value)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+)
+
+// formatBase returns the base to format integers in, so that parsing them in
+// base gives the same value. Base 0 accepts decimal integers.
+func formatBase(base int) int {
+	if base == 0 {
+		return 10
+	}
+	return base
+}
+
+// codec parses and formats values of a single type using reflection. It's used
+// for struct fields that are built out of other types, like slices.
+type codec struct {
+	parse  func(s string) (reflect.Value, error)
+	format func(v reflect.Value) string
+	isBool bool
+}
+
+// codecFor returns the codec for values of type t, using base to parse integers.
+// Types registered in r take precedence over the built-in types.
+func (r *TypeRegistry) codecFor(t reflect.Type, base int) (codec, bool) {
+	if c, ok := r.lookup(t); ok {
+		return c, true
+	}
+	pt := reflect.PointerTo(t)
+	switch {
+	case pt.Implements(flagValueType):
+		return codec{
+			parse: func(s string) (reflect.Value, error) {
+				p := reflect.New(t)
+				err := p.Interface().(flag.Value).Set(s)
+				return p.Elem(), err
+			},
+			format: func(v reflect.Value) string {
+				p := reflect.New(t)
+				p.Elem().Set(v)
+				return p.Interface().(flag.Value).String()
+			},
+		}, true
+	case pt.Implements(textUnmarshalerType):
+		return codec{
+			parse: func(s string) (reflect.Value, error) {
+				p := reflect.New(t)
+				err := p.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+				return p.Elem(), err
+			},
+			format: func(v reflect.Value) string {
+				p := reflect.New(t)
+				p.Elem().Set(v)
+				return textMarshal(p.Interface(), "")
+			},
+		}, true
+	case t == durationType:
+		return codec{
+			parse: func(s string) (reflect.Value, error) {
+				d, err := time.ParseDuration(s)
+				return reflect.ValueOf(d), err
+			},
+			format: func(v reflect.Value) string { return time.Duration(v.Int()).String() },
+		}, true
+	}
+
+	var parse func(s string, v reflect.Value) error
+	var format func(v reflect.Value) string
+	switch t.Kind() {
+	case reflect.Bool:
+		parse = func(s string, v reflect.Value) error {
+			b, err := strconv.ParseBool(s)
+			v.SetBool(b)
+			return err
+		}
+		format = func(v reflect.Value) string { return strconv.FormatBool(v.Bool()) }
+	case reflect.String:
+		parse = func(s string, v reflect.Value) error {
+			v.SetString(s)
+			return nil
+		}
+		format = func(v reflect.Value) string { return v.String() }
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parse = func(s string, v reflect.Value) error {
+			i, err := strconv.ParseInt(s, base, t.Bits())
+			v.SetInt(i)
+			return err
+		}
+		format = func(v reflect.Value) string { return strconv.FormatInt(v.Int(), formatBase(base)) }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		parse = func(s string, v reflect.Value) error {
+			u, err := strconv.ParseUint(s, base, t.Bits())
+			v.SetUint(u)
+			return err
+		}
+		format = func(v reflect.Value) string { return strconv.FormatUint(v.Uint(), formatBase(base)) }
+	case reflect.Float32, reflect.Float64:
+		parse = func(s string, v reflect.Value) error {
+			f, err := strconv.ParseFloat(s, t.Bits())
+			v.SetFloat(f)
+			return err
+		}
+		format = func(v reflect.Value) string { return strconv.FormatFloat(v.Float(), 'g', -1, t.Bits()) }
+	default:
+		return codec{}, false
+	}
+	return codec{
+		parse: func(s string) (reflect.Value, error) {
+			v := reflect.New(t).Elem()
+			err := parse(s, v)
+			return v, err
+		},
+		format: format,
+		isBool: t.Kind() == reflect.Bool,
+	}, true
+}
+
+// ptrValue is used by StructVar for pointer fields. The pointer stays nil until
+// the flag is set (or has a default value), which allows distinguishing an
+// unset flag from one explicitly set to the zero value.
+type ptrValue struct {
+	ptr      reflect.Value // addressable pointer
+	codec    codec
+	defvalue reflect.Value // invalid if there is no default value
+}
+
+func newPtrValue(ptr reflect.Value, c codec, defvalue string) (*ptrValue, error) {
+	p := &ptrValue{ptr: ptr, codec: c}
+	if defvalue != "" {
+		v, err := c.parse(defvalue)
+		if err != nil {
+			return nil, err
 		}
+		p.defvalue = v
+	}
+	p.Reset()
+	return p, nil
+}
+
+func (p *ptrValue) IsBoolFlag() bool { return p.codec.isBool }
+
+func (p *ptrValue) Set(s string) error {
+	v, err := p.codec.parse(s)
+	if err != nil {
+		return err
 	}
-	fs.Var(&textMarshalVar{p, value}, name, usage)
+	p.set(v)
+	return nil
 }
+
+func (p *ptrValue) set(v reflect.Value) {
+	ptr := reflect.New(p.ptr.Type().Elem())
+	ptr.Elem().Set(v)
+	p.ptr.Set(ptr)
+}
+
+func (p *ptrValue) Get() any { return p.ptr.Interface() }
+
+func (p *ptrValue) String() string {
+	if p == nil || !p.ptr.IsValid() || p.ptr.IsNil() {
+		return ""
+	}
+	return p.codec.format(p.ptr.Elem())
+}
+
+// Reset sets the pointer to a new copy of the default value, or nil if there isn't one.
+func (p *ptrValue) Reset() {
+	if p.defvalue.IsValid() {
+		p.set(p.defvalue)
+	} else {
+		p.ptr.Set(reflect.Zero(p.ptr.Type()))
+	}
+}
+
+// scalarValue is used by StructVar for fields of basic types, including named
+// types (eg - "type Port uint16").
+type scalarValue struct {
+	ptr      reflect.Value // addressable value
+	codec    codec
+	defvalue reflect.Value
+}
+
+func newScalarValue(ptr reflect.Value, c codec, defvalue string) (*scalarValue, error) {
+	v := &scalarValue{ptr: ptr, codec: c, defvalue: reflect.Zero(ptr.Type())}
+	if defvalue != "" {
+		def, err := c.parse(defvalue)
+		if err != nil {
+			return nil, err
+		}
+		v.defvalue = def
+	}
+	v.Reset()
+	return v, nil
+}
+
+func (v *scalarValue) IsBoolFlag() bool { return v.codec.isBool }
+
+func (v *scalarValue) Set(s string) error {
+	x, err := v.codec.parse(s)
+	if err != nil {
+		return err
+	}
+	v.ptr.Set(x)
+	return nil
+}
+
+func (v *scalarValue) Get() any { return v.ptr.Interface() }
+
+func (v *scalarValue) String() string {
+	if v == nil || !v.ptr.IsValid() {
+		return ""
+	}
+	return v.codec.format(v.ptr)
+}
+
+func (v *scalarValue) Reset() { v.ptr.Set(v.defvalue) }
@@ -148,3 +148,27 @@ func TestResettableFlagVarEdgeCases(t *testing.T) {
 		t.Errorf("Expected 'default', got %s", rv.String())
 	}
 }
+
+func TestParseIntRejectsOverflow(t *testing.T) {
+	if _, err := parseInt[int8]("300"); err == nil {
+		t.Error("expected error for int8 overflow")
+	}
+	if v, err := parseInt[int8]("-128"); err != nil || v != -128 {
+		t.Errorf("expected -128, got %d (%v)", v, err)
+	}
+	if _, err := parseUint[uint16]("70000"); err == nil {
+		t.Error("expected error for uint16 overflow")
+	}
+	if _, err := parseFloat[float32]("1e40"); err == nil {
+		t.Error("expected error for float32 overflow")
+	}
+
+	var i int64
+	v := newVar(&i, 1, parseInt[int64], formatInt[int64], false)
+	if err := v.Set("99999999999999999999"); err != errRange {
+		t.Errorf("expected errRange, got %v", err)
+	}
+	if i != 1 {
+		t.Errorf("expected value to be unchanged after error, got %d", i)
+	}
+}
@@ -0,0 +1,81 @@
+package flage
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ErrInactiveVariant is returned by Check for each flag that was set, but
+// belongs to a variant that wasn't selected. See the variant option of StructVar.
+var ErrInactiveVariant = errors.New("flag of an unselected variant")
+
+// variant is a nested struct whose flags are only used when its selector flag
+// is set to its choice, eg - the "s3" struct for "-storage=s3".
+type variant struct {
+	selector *structFlag
+	choice   string
+}
+
+func (v *variant) selected() bool { return v.selector.field.String() == v.choice }
+
+func (v *variant) String() string { return "-" + v.selector.names[0] + "=" + v.choice }
+
+// inactiveVariant returns the variant that s or a struct containing it belongs
+// to, if it isn't selected. Returns nil if all of them are selected.
+func (s *structInfo) inactiveVariant() *variant {
+	for ; s != nil; s = s.parent {
+		if s.variant != nil && !s.variant.selected() {
+			return s.variant
+		}
+	}
+	return nil
+}
+
+// pendingVariant is a variant whose selector hasn't been looked up yet, since
+// it may be declared after the variant.
+type pendingVariant struct {
+	*variant
+	selectorName string            // flag name of the selector
+	fieldErr     func(error) error // describes errors with the variant's field
+}
+
+// resolveVariants looks up the selectors of variants, adding their choices to
+// the selector, which only accepts the values of its choices (or "").
+func resolveVariants(fs *flag.FlagSet, pending []pendingVariant) error {
+	var selectors []*structFlag
+	for _, p := range pending {
+		sf := lookupStructFlag(fs, p.selectorName)
+		if sf == nil || sf.field.Kind() != reflect.String {
+			return p.fieldErr(fmt.Errorf("has a variant option, but -%s is not a string flag registered by StructVar", p.selectorName))
+		}
+		if slices.Contains(sf.choices, p.choice) {
+			return p.fieldErr(fmt.Errorf("has a duplicate variant of -%s: %s", p.selectorName, p.choice))
+		}
+		if len(sf.choices) == 0 {
+			selectors = append(selectors, sf)
+			sf.validators = append(sf.validators, sf.checkChoice)
+		}
+		sf.choices = append(sf.choices, p.choice)
+		p.variant.selector = sf
+	}
+	for _, sf := range selectors {
+		if sf.metavar == typeMetavar(sf.field.Type()) {
+			// show the choices, unless a metavar was given
+			sf.metavar = "{" + strings.Join(sf.choices, "|") + "}"
+		}
+	}
+	return nil
+}
+
+// checkChoice returns an error if v isn't empty or one of the choices of the
+// selector sf
+func (sf *structFlag) checkChoice(v reflect.Value) error {
+	if s := v.String(); s != "" && !slices.Contains(sf.choices, s) {
+		return fmt.Errorf("%q must be one of %s", s, strings.Join(sf.choices, ", "))
+	}
+	return nil
+}
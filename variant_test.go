@@ -0,0 +1,122 @@
+package flage
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"strings"
+	"testing"
+)
+
+type variantS3 struct {
+	Bucket string `flage:"bucket;required,,bucket to store files in"`
+	Region string `flage:"region,us-east-1"`
+}
+
+type variantDisk struct {
+	Path string `flage:"path,/var/lib/app" flage-validate:"nonempty"`
+}
+
+type variantStorage struct {
+	Kind   string      `flage:"storage,disk,storage backend"`
+	S3     variantS3   `flage:"s3;variant=storage"`
+	Disk   variantDisk `flage:"disk;variant=storage"`
+	Memory struct{}    `flage:"memory;variant=storage"`
+}
+
+func TestVariants(t *testing.T) {
+	var actual variantStorage
+	fs := FlagSetStruct("test", flag.ContinueOnError, &actual)
+	var out bytes.Buffer
+	fs.SetOutput(&out)
+
+	tests := []struct {
+		args []string
+		err  error
+	}{
+		{args: nil},
+		{args: []string{"-storage=s3", "-s3.bucket", "b"}},
+		{args: []string{"-storage=memory"}},
+		{args: []string{"-storage=disk", "-disk.path", ""}, err: &ValidationError{}},
+		{args: []string{"-storage=s3"}, err: ErrMissingRequiredFlag},
+		{args: []string{"-s3.bucket", "b"}, err: ErrInactiveVariant},
+		{args: []string{"-storage=memory", "-disk.path", "/tmp"}, err: ErrInactiveVariant},
+		{args: []string{"-storage=gcs"}, err: &ValidationError{}},
+	}
+	for _, tc := range tests {
+		fs.VisitAll(func(f *flag.Flag) { Reset(f.Value) })
+		if err := fs.Parse(tc.args); err != nil {
+			t.Fatalf("%v: failed to parse flags: %s", tc.args, err.Error())
+		}
+		err := Check(fs)
+		var ve *ValidationError
+		switch {
+		case tc.err == nil && err != nil:
+			t.Errorf("%v: unexpected error: %v", tc.args, err)
+		case tc.err == nil:
+		case errors.As(tc.err, &ve):
+			if !errors.As(err, &ve) {
+				t.Errorf("%v: expected a *ValidationError, got %v", tc.args, err)
+			}
+		case !errors.Is(err, tc.err):
+			t.Errorf("%v: expected %v, got %v", tc.args, tc.err, err)
+		}
+	}
+
+	t.Run("reports the selector of inactive flags", func(t *testing.T) {
+		fs.VisitAll(func(f *flag.Flag) { Reset(f.Value) })
+		if err := fs.Parse([]string{"-s3.bucket", "b"}); err != nil {
+			t.Fatalf("failed to parse flags: %s", err.Error())
+		}
+		if err := Check(fs); err == nil || !strings.Contains(err.Error(), "-s3.bucket requires -storage=s3") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("prints variants under their choice", func(t *testing.T) {
+		out.Reset()
+		fs.Usage()
+		for _, s := range []string{
+			"  -storage {s3|disk|memory}\n    \tstorage backend (default \"disk\")\n",
+			"\n-storage=s3:\n  -s3.bucket STRING\n",
+			"\n-storage=disk:\n  -disk.path STRING\n",
+		} {
+			if !strings.Contains(out.String(), s) {
+				t.Errorf("expected help to contain %q, got:\n%s", s, out.String())
+			}
+		}
+	})
+
+	t.Run("leaves inactive variants out of dumps", func(t *testing.T) {
+		v := variantStorage{Kind: "disk", S3: variantS3{Bucket: "b"}, Disk: variantDisk{Path: "/data"}}
+		if got := strings.Join(CommandString(&v), " "); got != "-disk.path /data" {
+			t.Errorf("unexpected args: %s", got)
+		}
+		var config bytes.Buffer
+		PrintConfig(&config, fs)
+		if strings.Contains(config.String(), "s3.") {
+			t.Errorf("expected no inactive flags, got:\n%s", config.String())
+		}
+	})
+
+	t.Run("rejects invalid variants", func(t *testing.T) {
+		var missing struct {
+			S3 variantS3 `flage:"s3;variant=storage"`
+		}
+		var notStruct struct {
+			Kind string `flage:"kind"`
+			Name string `flage:"name;variant=kind"`
+		}
+		var duplicate struct {
+			Kind string    `flage:"kind"`
+			A    variantS3 `flage:"s3;variant=kind"`
+			B    struct{}  `flage:"s3;variant=kind"`
+		}
+		for _, v := range []any{&missing, &notStruct, &duplicate} {
+			var fe *FieldError
+			if err := StructVarE(v, flag.NewFlagSet("", flag.ContinueOnError)); !errors.As(err, &fe) {
+				t.Errorf("expected a *FieldError for %T, got %v", v, err)
+			}
+		}
+	})
+}